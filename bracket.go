@@ -0,0 +1,158 @@
+package ignore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// runeRange is an inclusive range of runes.
+type runeRange struct {
+	lo, hi rune
+}
+
+// namedClasses lists the POSIX character classes supported by git within
+// bracket expressions, such as "[[:digit:]]". Like git, they only cover
+// ASCII characters.
+var namedClasses = map[string][]runeRange{
+	"alnum":  {{'0', '9'}, {'A', 'Z'}, {'a', 'z'}},
+	"alpha":  {{'A', 'Z'}, {'a', 'z'}},
+	"blank":  {{'\t', '\t'}, {' ', ' '}},
+	"cntrl":  {{0x00, 0x1f}, {0x7f, 0x7f}},
+	"digit":  {{'0', '9'}},
+	"graph":  {{'!', '~'}},
+	"lower":  {{'a', 'z'}},
+	"print":  {{' ', '~'}},
+	"punct":  {{'!', '/'}, {':', '@'}, {'[', '`'}, {'{', '~'}},
+	"space":  {{'\t', '\r'}, {' ', ' '}},
+	"upper":  {{'A', 'Z'}},
+	"xdigit": {{'0', '9'}, {'A', 'F'}, {'a', 'f'}},
+}
+
+// charClass is a parsed bracket expression, such as "[a-z]" or "[!0-9]".
+type charClass struct {
+	negate bool
+	ranges []runeRange
+}
+
+// parseBracket parses the bracket expression at the start of `p`, which
+// must begin with "[", following git's wildmatch. It returns the
+// normalized class and the number of bytes it spans, or a reason if the
+// expression is invalid.
+func parseBracket(p string) (*charClass, int, string) {
+	cc := &charClass{}
+	i := 1
+	if i < len(p) && (p[i] == '!' || p[i] == '^') {
+		cc.negate = true
+		i++
+	}
+
+	// prev is the last single character added, the start of a range
+	prev := rune(-1)
+	for first := true; ; first = false {
+		if i >= len(p) {
+			return nil, 0, "unterminated bracket expression"
+		}
+		c, n := utf8.DecodeRuneInString(p[i:])
+		switch {
+		case c == ']' && !first:
+			cc.normalize()
+			return cc, i + 1, ""
+		case c == '\\':
+			i += n
+			if i >= len(p) {
+				return nil, 0, "unterminated bracket expression"
+			}
+			c, n = utf8.DecodeRuneInString(p[i:])
+			cc.ranges = append(cc.ranges, runeRange{c, c})
+			prev = c
+			i += n
+		case c == '-' && prev >= 0 && i+1 < len(p) && p[i+1] != ']':
+			i += n
+			hi, n := utf8.DecodeRuneInString(p[i:])
+			if hi == '\\' {
+				i += n
+				if i >= len(p) {
+					return nil, 0, "unterminated bracket expression"
+				}
+				hi, n = utf8.DecodeRuneInString(p[i:])
+			}
+			// The single character was already added, extend it, unless
+			// the range is reversed: like git, it still matches itself
+			if hi >= prev {
+				cc.ranges[len(cc.ranges)-1] = runeRange{prev, hi}
+			}
+			prev = -1
+			i += n
+		case c == '[' && i+1 < len(p) && p[i+1] == ':':
+			end := strings.IndexByte(p[i+2:], ']')
+			if end < 0 {
+				return nil, 0, "unterminated bracket expression"
+			}
+			name := p[i+2 : i+2+end]
+			if name == "" || name[len(name)-1] != ':' {
+				// Not a "[:class:]", so the "[" is an ordinary character
+				cc.ranges = append(cc.ranges, runeRange{'[', '['})
+				prev = '['
+				i++
+				continue
+			}
+			ranges, ok := namedClasses[name[:len(name)-1]]
+			if !ok {
+				return nil, 0, fmt.Sprintf("unknown character class %q", "[:"+name+"]")
+			}
+			cc.ranges = append(cc.ranges, ranges...)
+			prev = -1
+			i += end + 3
+		default:
+			cc.ranges = append(cc.ranges, runeRange{c, c})
+			prev = c
+			i += n
+		}
+	}
+}
+
+// normalize sorts and merges the ranges of the class.
+func (cc *charClass) normalize() {
+	ranges := cc.ranges
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].lo < ranges[j].lo })
+
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.lo <= merged[n-1].hi+1 {
+			if r.hi > merged[n-1].hi {
+				merged[n-1].hi = r.hi
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	cc.ranges = merged
+}
+
+// matches returns true if the class matches the rune `r`, regardless of
+// the case of ASCII letters if `ignoreCase` is true, like git. Like a
+// wildcard, it never matches a "/".
+func (cc *charClass) matches(r rune, ignoreCase bool) bool {
+	if r == '/' {
+		return false
+	}
+	found := cc.contains(r)
+	if !found && ignoreCase {
+		switch {
+		case 'a' <= r && r <= 'z':
+			found = cc.contains(r - 'a' + 'A')
+		case 'A' <= r && r <= 'Z':
+			found = cc.contains(r - 'A' + 'a')
+		}
+	}
+	return found != cc.negate
+}
+
+// contains returns true if the rune `r` is within one of the ranges of
+// the class, which must be normalized.
+func (cc *charClass) contains(r rune) bool {
+	i := sort.Search(len(cc.ranges), func(i int) bool { return cc.ranges[i].hi >= r })
+	return i < len(cc.ranges) && cc.ranges[i].lo <= r
+}
@@ -0,0 +1,140 @@
+package ignore
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+// matchCache is a bounded cache of the decisions made for directories, the
+// least recently used one being evicted first. It is safe for concurrent
+// use.
+type matchCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	lru     list.List // of *cacheEntry, the most recently used first
+	// gen is incremented by reset, so that the decisions computed before
+	// are not stored afterwards
+	gen uint64
+}
+
+// cacheEntry is the decision made for the directory `dir`: the index of
+// the pattern deciding whether it is ignored, or -1 if none targets it.
+type cacheEntry struct {
+	dir  string
+	best int
+}
+
+// newMatchCache returns an empty cache holding up to `size` directories.
+func newMatchCache(size int) *matchCache {
+	return &matchCache{size: size, entries: map[string]*list.Element{}}
+}
+
+// get returns the decision cached for the directory `dir`, if any, and
+// the generation of the cache, to be given to put.
+func (c *matchCache) get(dir string) (int, bool, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[dir]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*cacheEntry).best, true, c.gen
+	}
+	return -1, false, c.gen
+}
+
+// put caches the decision `best` for the directory `dir`, unless the cache
+// was reset since generation `gen`.
+func (c *matchCache) put(dir string, best int, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	if e, ok := c.entries[dir]; ok {
+		e.Value.(*cacheEntry).best = best
+		c.lru.MoveToFront(e)
+		return
+	}
+	if c.lru.Len() >= c.size {
+		e := c.lru.Back()
+		delete(c.entries, e.Value.(*cacheEntry).dir)
+		c.lru.Remove(e)
+	}
+	// Copy the key, which may be a slice of a much longer path
+	dir = string(append([]byte(nil), dir...))
+	c.entries[dir] = c.lru.PushFront(&cacheEntry{dir: dir, best: best})
+}
+
+// reset drops all the cached decisions.
+func (c *matchCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+	c.gen++
+}
+
+// len returns the number of cached directories.
+func (c *matchCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// WithMatchCache makes the GitIgnore object remember the decisions made for
+// the parent directories of the matched paths, up to `size` directories,
+// so that the siblings of a path are only matched against the patterns
+// themselves, and those under an excluded directory are answered at once
+// with WithParentExclusion. The cache is safe for concurrent use, and is
+// cleared whenever patterns are added. It is disabled if `size` is not
+// positive.
+func WithMatchCache(size int) Option {
+	return func(gi *GitIgnore) {
+		gi.cache = nil
+		if size > 0 {
+			gi.cache = newMatchCache(size)
+		}
+	}
+}
+
+// ClearCache drops the decisions remembered with WithMatchCache. Adding
+// patterns already does it.
+func (gi *GitIgnore) ClearCache() {
+	if gi.cache != nil {
+		gi.cache.reset()
+	}
+}
+
+// cachedMatch is like match, for a cleaned path `f` relative to the base
+// directory, but takes the decision made for the parent directory of `f`
+// from the cache. It returns the index of the pattern, or -1.
+func (gi *GitIgnore) cachedMatch(f string, isDir bool, exact bool) int {
+	if isDir {
+		return gi.dirMatch(f)
+	}
+	parent := -1
+	if i := strings.LastIndexByte(f, '/'); i >= 0 {
+		parent = gi.dirMatch(f[:i])
+	}
+	if !exact && !gi.index.negated && parent >= 0 {
+		return parent
+	}
+	return gi.decide(f, false, parent)
+}
+
+// dirMatch returns the index of the pattern deciding whether the directory
+// `dir` is ignored, or -1, filling the cache for it and its parents.
+func (gi *GitIgnore) dirMatch(dir string) int {
+	best, ok, gen := gi.cache.get(dir)
+	if ok {
+		return best
+	}
+	parent := -1
+	if i := strings.LastIndexByte(dir, '/'); i >= 0 {
+		parent = gi.dirMatch(dir[:i])
+	}
+	best = gi.decide(dir, true, parent)
+	gi.cache.put(dir, best, gen)
+	return best
+}
@@ -0,0 +1,176 @@
+package ignore
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchCache(test *testing.T) {
+	c := newMatchCache(2)
+	_, ok, gen := c.get("a")
+	assert.False(test, ok)
+	c.put("a", 1, gen)
+	c.put("b", -1, gen)
+
+	best, ok, _ := c.get("a")
+	assert.True(test, ok)
+	assert.Equal(test, 1, best)
+
+	// "b" is the least recently used one
+	c.put("c", 2, gen)
+	assert.Equal(test, 2, c.len())
+	_, ok, _ = c.get("b")
+	assert.False(test, ok)
+	_, ok, _ = c.get("a")
+	assert.True(test, ok)
+
+	c.reset()
+	assert.Equal(test, 0, c.len())
+
+	// Decisions made before the reset are not stored
+	c.put("a", 1, gen)
+	assert.Equal(test, 0, c.len())
+}
+
+func TestWithMatchCache(test *testing.T) {
+	object := New(WithMatchCache(10)).AddPatternsFromLines("build/", "!build/keep")
+
+	assert.True(test, object.MatchesPath("build/a/b.txt"))
+	assert.False(test, object.MatchesPath("build/keep"))
+	assert.True(test, object.MatchesPath("src/build/"))
+	assert.Equal(test, 4, object.cache.len(), "build, build/a, src and src/build")
+
+	// Adding patterns clears the cache
+	object.AddPatternsFromLines("src/")
+	assert.Equal(test, 0, object.cache.len())
+	assert.True(test, object.MatchesPath("src/main.go"))
+
+	object.ClearCache()
+	assert.Equal(test, 0, object.cache.len())
+
+	// The cache is bounded
+	for i := 0; i < 20; i++ {
+		object.MatchesPath(fmt.Sprintf("dir%d/file", i))
+	}
+	assert.Equal(test, 10, object.cache.len())
+
+	// The cache is cleared once per call, not once per pattern
+	_, _, gen := object.cache.get("")
+	object.AddPatternsFromLines(templateLines()...)
+	_, _, next := object.cache.get("")
+	assert.Equal(test, gen+1, next)
+
+	assert.Nil(test, New(WithMatchCache(0)).cache)
+}
+
+func TestWithMatchCacheParentExclusion(test *testing.T) {
+	object := New(WithMatchCache(10), WithParentExclusion()).AddPatternsFromLines("abc", "!abc/b")
+
+	assert.True(test, object.MatchesPath("abc/b/b.js"))
+	assert.Equal(test, "abc", object.Match("abc/b/").Pattern)
+	assert.Nil(test, object.Match("def/b.js"))
+}
+
+// Validate that the cache does not change the decisions, for any options
+func TestWithMatchCacheRandom(test *testing.T) {
+	pieces := []string{"a", "b", ".", "/", "*", "?", "**", "A", "!", "x/"}
+	texts := []string{"a", "b", "A", ".", "/", "x"}
+	rng := rand.New(rand.NewSource(3))
+	options := [][]Option{
+		nil,
+		{WithParentExclusion()},
+		{WithIgnoreCase()},
+		{WithBase("x")},
+	}
+
+	for i := 0; i < 500; i++ {
+		var lines []string
+		for n := rng.Intn(8) + 1; n > 0; n-- {
+			var p strings.Builder
+			for m := rng.Intn(3) + 1; m > 0; m-- {
+				p.WriteString(pieces[rng.Intn(len(pieces))])
+			}
+			lines = append(lines, p.String())
+		}
+		opts := options[rng.Intn(len(options))]
+		expected := New(opts...).AddPatternsFromLines(lines...)
+		object := New(append(opts, WithMatchCache(4))...).AddPatternsFromLines(lines...)
+
+		for j := 0; j < 20; j++ {
+			var t strings.Builder
+			for n := rng.Intn(8) + 1; n > 0; n-- {
+				t.WriteString(texts[rng.Intn(len(texts))])
+			}
+			text := t.String()
+			if !assert.Equal(test, expected.Match(text), object.Match(text), "%q against %q", lines, text) {
+				return
+			}
+			assert.Equal(test, expected.MatchesPath(text), object.MatchesPath(text), "%q against %q", lines, text)
+		}
+	}
+}
+
+func TestWithMatchCacheConcurrent(test *testing.T) {
+	object := New(WithMatchCache(8), WithParentExclusion()).AddPatternsFromLines(templateLines()...)
+	expected := New(WithParentExclusion()).AddPatternsFromLines(templateLines()...)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				f := templatePaths[(i+j)%len(templatePaths)]
+				assert.Equal(test, expected.MatchesPath(f), object.MatchesPath(f), f)
+				if j%50 == 0 {
+					object.ClearCache()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// siblingPaths are many files within a few directories, as queried by a
+// walker
+var siblingPaths = func() []string {
+	var paths []string
+	for _, dir := range []string{"src/pkg/internal", "node_modules/lib/dist", "docs/api"} {
+		for i := 0; i < 100; i++ {
+			paths = append(paths, fmt.Sprintf("%s/file%d.go", dir, i))
+		}
+	}
+	return paths
+}()
+
+func benchmarkMatchesPathSiblings(b *testing.B, opts ...Option) {
+	object := New(opts...).AddPatternsFromLines(templateLines()...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, f := range siblingPaths {
+			object.MatchesPath(f)
+		}
+	}
+}
+
+func BenchmarkMatchesPathSiblings(b *testing.B) {
+	benchmarkMatchesPathSiblings(b)
+}
+
+func BenchmarkMatchesPathSiblingsCache(b *testing.B) {
+	benchmarkMatchesPathSiblings(b, WithMatchCache(1024))
+}
+
+func BenchmarkMatchesPathSiblingsParentExclusion(b *testing.B) {
+	benchmarkMatchesPathSiblings(b, WithParentExclusion())
+}
+
+func BenchmarkMatchesPathSiblingsParentExclusionCache(b *testing.B) {
+	benchmarkMatchesPathSiblings(b, WithParentExclusion(), WithMatchCache(1024))
+}
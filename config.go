@@ -0,0 +1,501 @@
+package ignore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxIncludeDepth is the number of nested includes git follows before
+// giving up, which catches include loops.
+const maxIncludeDepth = 10
+
+// Config holds the variables read from git config files, such as
+// core.excludesFile or core.ignoreCase. Files are read in increasing order
+// of precedence, so the last value of a variable wins. The loaders of
+// this package apply core.excludesFile and core.ignoreCase. Applying
+// core.precomposeUnicode is out of scope: it can be read, but paths and
+// patterns are always matched as given, without normalizing them to NFC.
+type Config struct {
+	gitDir string
+	vars   map[string][]string
+}
+
+// NewConfig returns an empty Config for the repository whose git directory
+// is `gitDir`, which is used to evaluate `includeIf "gitdir:..."`
+// sections. It may be empty if there is no repository.
+func NewConfig(gitDir string) *Config {
+	return &Config{gitDir: gitDir, vars: map[string][]string{}}
+}
+
+// LoadConfig reads the global and local git config files of the work tree
+// at `root`, which are $XDG_CONFIG_HOME/git/config (or ~/.config/git/config),
+// ~/.gitconfig and $GIT_DIR/config, which is read from the main git
+// directory for a linked work tree. Missing files are skipped.
+func LoadConfig(root string) (*Config, error) {
+	gitDir, err := findGitDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	home, _ := os.UserHomeDir()
+	xdg := xdgConfigHome()
+
+	var fpaths []string
+	if xdg != "" {
+		fpaths = append(fpaths, filepath.Join(xdg, "git", "config"))
+	}
+	if home != "" {
+		fpaths = append(fpaths, filepath.Join(home, ".gitconfig"))
+	}
+	if gitDir != "" {
+		commonDir, err := findCommonDir(gitDir)
+		if err != nil {
+			return nil, err
+		}
+		fpaths = append(fpaths, filepath.Join(commonDir, "config"))
+	}
+
+	c := NewConfig(gitDir)
+	for _, fpath := range fpaths {
+		if err := c.ReadFile(fpath); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// GitDir returns the git directory given to NewConfig.
+func (c *Config) GitDir() string {
+	return c.gitDir
+}
+
+// ReadFile reads the git config file at `fpath`, following its include.path
+// and includeIf.<condition>.path variables. Its values take precedence over
+// the ones read before.
+func (c *Config) ReadFile(fpath string) error {
+	return c.readFile(fpath, 0)
+}
+
+func (c *Config) readFile(fpath string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("exceeded maximum include depth (%d) while including %s", maxIncludeDepth, fpath)
+	}
+	buffer, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return err
+	}
+	// Like git, skip the UTF-8 byte order mark some editors write
+	data := strings.TrimPrefix(string(buffer), "\ufeff")
+	p := &configParser{data: data, source: fpath, line: 1}
+	return p.parse(func(section, subsection, name, value string) error {
+		c.add(section, subsection, name, value)
+		if name != "path" || !c.includes(section, subsection, fpath) {
+			return nil
+		}
+		// Missing included files are silently ignored, like git does
+		include := c.expandIncludePath(value, fpath)
+		if err := c.readFile(include, depth+1); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+// add appends a value to the variable, whose names are normalized: the
+// section and the variable name are case-insensitive, the subsection is
+// not.
+func (c *Config) add(section, subsection, name, value string) {
+	key := strings.ToLower(section) + "." + strings.ToLower(name)
+	if subsection != "" {
+		key = strings.ToLower(section) + "." + subsection + "." + strings.ToLower(name)
+	}
+	c.vars[key] = append(c.vars[key], value)
+}
+
+// normalizeKey lower cases the section and variable name of a key such as
+// "core.excludesFile" or "includeIf.gitdir:~/work/.path".
+func normalizeKey(key string) string {
+	first := strings.IndexByte(key, '.')
+	last := strings.LastIndexByte(key, '.')
+	if first < 0 {
+		return strings.ToLower(key)
+	}
+	return strings.ToLower(key[:first]) + key[first:last] + strings.ToLower(key[last:])
+}
+
+// Get returns the last value of the variable `key`, such as
+// "core.excludesFile", and whether it is set at all. A variable declared
+// without "=" has an empty value.
+func (c *Config) Get(key string) (string, bool) {
+	value, ok := c.last(key)
+	if value == configNoValue {
+		value = ""
+	}
+	return value, ok
+}
+
+// GetAll returns every value of the multi-valued variable `key`.
+func (c *Config) GetAll(key string) []string {
+	values := append([]string(nil), c.vars[normalizeKey(key)]...)
+	for i, value := range values {
+		if value == configNoValue {
+			values[i] = ""
+		}
+	}
+	return values
+}
+
+// last returns the last raw value of the variable `key`.
+func (c *Config) last(key string) (string, bool) {
+	values := c.vars[normalizeKey(key)]
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[len(values)-1], true
+}
+
+// GetBool returns the last value of the variable `key` as a boolean, and
+// whether it is set to a valid boolean. Like git, "true", "yes", "on",
+// "1" and a variable without value are true, while "false", "no", "off",
+// "0" and an empty value are false.
+func (c *Config) GetBool(key string) (bool, bool) {
+	value, ok := c.last(key)
+	if !ok {
+		return false, false
+	}
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1", configNoValue:
+		return true, true
+	case "false", "no", "off", "0", "":
+		return false, true
+	}
+	return false, false
+}
+
+// GetPath returns the last value of the variable `key` as a path: "~/"
+// stands for the home directory of the user.
+func (c *Config) GetPath(key string) (string, bool) {
+	value, ok := c.last(key)
+	if !ok || value == configNoValue {
+		return "", false
+	}
+	return expandHome(value), true
+}
+
+// includes returns true if the variables of `section` and `subsection`
+// read from `fpath` include another file.
+func (c *Config) includes(section, subsection, fpath string) bool {
+	switch strings.ToLower(section) {
+	case "include":
+		return subsection == ""
+	case "includeif":
+		return c.matchesCondition(subsection, fpath)
+	}
+	return false
+}
+
+// matchesCondition evaluates the condition of an includeIf section. Only
+// "gitdir:" and "gitdir/i:" are supported, other conditions are false.
+func (c *Config) matchesCondition(condition, fpath string) bool {
+	var pattern string
+	ignoreCase := false
+	switch {
+	case strings.HasPrefix(condition, "gitdir:"):
+		pattern = condition[len("gitdir:"):]
+	case strings.HasPrefix(condition, "gitdir/i:"):
+		pattern = condition[len("gitdir/i:"):]
+		ignoreCase = true
+	default:
+		return false
+	}
+	if c.gitDir == "" || pattern == "" {
+		return false
+	}
+	gitDir, err := filepath.Abs(c.gitDir)
+	if err != nil {
+		return false
+	}
+
+	// Like git, "./" is relative to the config file, "~/" to the home
+	// directory, any other relative pattern matches at any depth and a
+	// trailing "/" matches everything inside
+	subtree := strings.HasSuffix(pattern, "/")
+	switch {
+	case strings.HasPrefix(pattern, "./"):
+		pattern = filepath.ToSlash(filepath.Dir(fpath)) + pattern[1:]
+	case strings.HasPrefix(pattern, "~/"):
+		pattern = filepath.ToSlash(expandHome(pattern))
+	case !strings.HasPrefix(pattern, "/") && !filepath.IsAbs(pattern):
+		pattern = "**/" + pattern
+	}
+	if subtree {
+		pattern = strings.TrimSuffix(pattern, "/") + "/**"
+	}
+
+	tokens, perr := tokenize(strings.TrimPrefix(pattern, "/"))
+	if tokens == nil && perr != nil {
+		return false
+	}
+	return wildmatch(tokens, strings.TrimPrefix(filepath.ToSlash(gitDir), "/"), true, ignoreCase)
+}
+
+// expandIncludePath resolves the path of an included file: "~/" stands
+// for the home directory and relative paths are relative to the directory
+// of the file including it.
+func (c *Config) expandIncludePath(value, fpath string) string {
+	value = filepath.FromSlash(expandHome(value))
+	if !filepath.IsAbs(value) {
+		value = filepath.Join(filepath.Dir(fpath), value)
+	}
+	return value
+}
+
+// xdgConfigHome returns $XDG_CONFIG_HOME, which defaults to ~/.config, or
+// an empty string if the home directory is unknown.
+func xdgConfigHome() string {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return xdg
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".config")
+	}
+	return ""
+}
+
+// expandHome replaces a leading "~/" by the home directory of the user.
+func expandHome(value string) string {
+	if value != "~" && !strings.HasPrefix(value, "~/") {
+		return value
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return value
+	}
+	return filepath.Join(home, value[1:])
+}
+
+// configNoValue is the value of a variable declared without "=", such as
+// "bare" in "[core] bare". It cannot be confused with a real value, which
+// never holds a NUL character.
+const configNoValue = "\x00"
+
+// configParser reads the INI-like syntax of git config files.
+type configParser struct {
+	data   string
+	pos    int
+	line   int
+	source string
+}
+
+// parse calls `fn` for each variable of the file, in order.
+func (p *configParser) parse(fn func(section, subsection, name, value string) error) error {
+	var section, subsection string
+	for {
+		p.skipSpace(true)
+		if p.pos >= len(p.data) {
+			return nil
+		}
+		switch c := p.data[p.pos]; {
+		case c == '#' || c == ';':
+			p.skipLine()
+		case c == '[':
+			var err error
+			if section, subsection, err = p.parseSection(); err != nil {
+				return err
+			}
+		case isConfigAlpha(c):
+			if section == "" {
+				return p.errorf()
+			}
+			name, value, err := p.parseVariable()
+			if err != nil {
+				return err
+			}
+			if err := fn(section, subsection, name, value); err != nil {
+				return err
+			}
+		default:
+			return p.errorf()
+		}
+	}
+}
+
+// errorf returns the error for the current line, worded like git's.
+func (p *configParser) errorf() error {
+	return fmt.Errorf("bad config line %d in file %s", p.line, p.source)
+}
+
+// skipSpace skips blanks, and new lines if `newlines` is true.
+func (p *configParser) skipSpace(newlines bool) {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\r':
+		case '\n':
+			if !newlines {
+				return
+			}
+			p.line++
+		default:
+			return
+		}
+		p.pos++
+	}
+}
+
+// skipLine skips everything up to the next line.
+func (p *configParser) skipLine() {
+	for p.pos < len(p.data) && p.data[p.pos] != '\n' {
+		p.pos++
+	}
+}
+
+// parseSection parses a header such as `[core]`, `[remote "origin"]` or
+// the deprecated `[branch.main]`.
+func (p *configParser) parseSection() (string, string, error) {
+	p.pos++
+	start := p.pos
+	for p.pos < len(p.data) && (isConfigAlnum(p.data[p.pos]) || p.data[p.pos] == '-' || p.data[p.pos] == '.') {
+		p.pos++
+	}
+	section := p.data[start:p.pos]
+	if section == "" || p.pos >= len(p.data) {
+		return "", "", p.errorf()
+	}
+
+	if p.data[p.pos] == ']' {
+		p.pos++
+		if i := strings.IndexByte(section, '.'); i >= 0 {
+			return section[:i], strings.ToLower(section[i+1:]), nil
+		}
+		return section, "", nil
+	}
+
+	p.skipSpace(false)
+	if p.pos >= len(p.data) || p.data[p.pos] != '"' {
+		return "", "", p.errorf()
+	}
+	p.pos++
+	var subsection strings.Builder
+	for {
+		if p.pos >= len(p.data) || p.data[p.pos] == '\n' {
+			return "", "", p.errorf()
+		}
+		c := p.data[p.pos]
+		p.pos++
+		if c == '"' {
+			break
+		}
+		if c == '\\' && p.pos < len(p.data) && p.data[p.pos] != '\n' {
+			c = p.data[p.pos]
+			p.pos++
+		}
+		subsection.WriteByte(c)
+	}
+	if p.pos >= len(p.data) || p.data[p.pos] != ']' {
+		return "", "", p.errorf()
+	}
+	p.pos++
+	return section, subsection.String(), nil
+}
+
+// parseVariable parses a line such as `name = value` or `name`.
+func (p *configParser) parseVariable() (string, string, error) {
+	start := p.pos
+	for p.pos < len(p.data) && (isConfigAlnum(p.data[p.pos]) || p.data[p.pos] == '-') {
+		p.pos++
+	}
+	name := p.data[start:p.pos]
+
+	p.skipSpace(false)
+	if p.pos >= len(p.data) || p.data[p.pos] == '\n' {
+		return name, configNoValue, nil
+	}
+	switch p.data[p.pos] {
+	case '=':
+		p.pos++
+	case '#', ';':
+		p.skipLine()
+		return name, configNoValue, nil
+	default:
+		return "", "", p.errorf()
+	}
+	value, err := p.parseValue()
+	return name, value, err
+}
+
+// parseValue parses the value of a variable up to the end of the line,
+// handling quotes, escapes, comments and line continuations. Unquoted
+// blanks are trimmed at both ends, and each inner one becomes a space.
+func (p *configParser) parseValue() (string, error) {
+	var buf strings.Builder
+	quoted := false
+	spaces := 0
+	for ; p.pos < len(p.data); p.pos++ {
+		c := p.data[p.pos]
+		if c == '\n' {
+			if quoted {
+				return "", p.errorf()
+			}
+			break
+		}
+		if !quoted && (c == ' ' || c == '\t' || c == '\r') {
+			if buf.Len() > 0 {
+				spaces++
+			}
+			continue
+		}
+		if !quoted && (c == '#' || c == ';') {
+			p.skipLine()
+			break
+		}
+		for ; spaces > 0; spaces-- {
+			buf.WriteByte(' ')
+		}
+		switch c {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			p.pos++
+			if p.pos >= len(p.data) {
+				return "", p.errorf()
+			}
+			switch e := p.data[p.pos]; e {
+			case '\n':
+				// Line continuation
+				p.line++
+			case '\r':
+				if p.pos+1 < len(p.data) && p.data[p.pos+1] == '\n' {
+					p.pos++
+					p.line++
+				}
+			case 'n':
+				buf.WriteByte('\n')
+			case 't':
+				buf.WriteByte('\t')
+			case 'b':
+				buf.WriteByte('\b')
+			case '\\', '"':
+				buf.WriteByte(e)
+			default:
+				return "", p.errorf()
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	if quoted {
+		return "", p.errorf()
+	}
+	return buf.String(), nil
+}
+
+func isConfigAlpha(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isConfigAlnum(c byte) bool {
+	return isConfigAlpha(c) || ('0' <= c && c <= '9')
+}
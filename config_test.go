@@ -0,0 +1,167 @@
+package ignore
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigReadFile(test *testing.T) {
+	home := isolateHomeForTest(test)
+	config := NewConfig(filepath.Join(home, "work", "repo", ".git"))
+	assert.NoError(test, config.ReadFile("./test_fixtures/config/basic.gitconfig"))
+
+	value, ok := config.Get("core.excludesFile")
+	assert.True(test, ok)
+	assert.Equal(test, "~/.gitignore_global", value)
+	value, ok = config.GetPath("CORE.EXCLUDESFILE")
+	assert.True(test, ok)
+	assert.Equal(test, filepath.Join(home, ".gitignore_global"), value)
+
+	// Overridden by the included file
+	ignoreCase, ok := config.GetBool("core.ignorecase")
+	assert.True(test, ok)
+	assert.False(test, ignoreCase)
+
+	precompose, ok := config.GetBool("core.precomposeUnicode")
+	assert.True(test, ok)
+	assert.True(test, precompose)
+	value, ok = config.Get("core.precomposeUnicode")
+	assert.True(test, ok)
+	assert.Equal(test, "", value)
+
+	_, ok = config.GetBool("core.autocrlf")
+	assert.False(test, ok, "input is not a boolean")
+	_, ok = config.Get("core.bare")
+	assert.False(test, ok)
+
+	assertConfigValue(test, config, "core.autocrlf", "input")
+	assertConfigValue(test, config, "user.name", `John "Q" Doe`)
+	assertConfigValue(test, config, "remote.origin.url", "https://example.com/repo.git")
+	assert.Equal(test, []string{"+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*"}, config.GetAll("remote.origin.fetch"))
+	assertConfigValue(test, config, "branch.Main.remote", "origin")
+	_, ok = config.Get("branch.main.remote")
+	assert.False(test, ok, "subsections are case sensitive")
+	assertConfigValue(test, config, "alias.legacy.st", "status")
+
+	assertConfigValue(test, config, "multi.oneline", "yes")
+	assertConfigValue(test, config, "multi.continued", "first second")
+	assertConfigValue(test, config, "multi.spaced", "  keep  ")
+	assertConfigValue(test, config, "multi.collapsed", "a   b")
+	assertConfigValue(test, config, "multi.escapes", "tab\there\\backslash")
+	assertConfigValue(test, config, "multi.empty", "")
+	empty, ok := config.GetBool("multi.empty")
+	assert.True(test, ok)
+	assert.False(test, empty)
+
+	// Included files
+	assertConfigValue(test, config, "included.value", "yes")
+	assertConfigValue(test, config, "user.email", "doe@work.example.com")
+	assertConfigValue(test, config, "repo.included", "on")
+	assert.Len(test, config.GetAll("include.path"), 2)
+}
+
+func TestConfigIncludeIfGitDir(test *testing.T) {
+	isolateHomeForTest(test)
+	config := NewConfig(filepath.Join(test.TempDir(), "other", ".git"))
+	assert.NoError(test, config.ReadFile("./test_fixtures/config/basic.gitconfig"))
+
+	assertConfigValue(test, config, "user.email", "doe@example.com")
+	_, ok := config.Get("repo.included")
+	assert.False(test, ok)
+
+	config = NewConfig("")
+	assert.NoError(test, config.ReadFile("./test_fixtures/config/basic.gitconfig"))
+	assertConfigValue(test, config, "user.email", "doe@example.com")
+}
+
+func TestConfigReadFileErrors(test *testing.T) {
+	for _, name := range []string{"bad-section", "bad-quote", "no-section"} {
+		fpath := "./test_fixtures/config/" + name + ".gitconfig"
+		err := NewConfig("").ReadFile(fpath)
+		assert.Error(test, err, name)
+	}
+
+	err := NewConfig("").ReadFile("./test_fixtures/config/bad-quote.gitconfig")
+	assert.EqualError(test, err, "bad config line 2 in file ./test_fixtures/config/bad-quote.gitconfig")
+
+	err = NewConfig("").ReadFile("./test_fixtures/config/loop.gitconfig")
+	assert.Error(test, err, "include loops should be caught")
+
+	err = NewConfig("").ReadFile("./test_fixtures/config/doesntexist")
+	assert.Error(test, err)
+}
+
+func TestConfigReadFileByteOrderMark(test *testing.T) {
+	dir := test.TempDir()
+	writeFileToDir(test, dir, "bom.gitconfig", "\ufeff[core]\n\tignoreCase = true\n")
+
+	config := NewConfig("")
+	assert.NoError(test, config.ReadFile(filepath.Join(dir, "bom.gitconfig")))
+	ignoreCase, ok := config.GetBool("core.ignoreCase")
+	assert.True(test, ok)
+	assert.True(test, ignoreCase)
+
+	// Only at the start of the file
+	writeFileToDir(test, dir, "bom.gitconfig", "[core]\n\ufeff[user]\n")
+	assert.Error(test, NewConfig("").ReadFile(filepath.Join(dir, "bom.gitconfig")))
+
+	home := isolateHomeForTest(test)
+	writeFileToDir(test, home, ".gitconfig", "\ufeff[core]\n\tignoreCase = true\n")
+	root := writeTreeToTestDir(test, map[string]string{
+		".gitignore": "*.LOG\n",
+	})
+	r, err := NewRepository(root)
+	assert.NoError(test, err)
+	assert.True(test, r.MatchesPath("debug.log"), "debug.log should match")
+}
+
+func TestLoadConfigIgnoreCase(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeTreeToTestDir(test, map[string]string{
+		".git/config":       "[core]\n\tignoreCase = true\n",
+		".git/info/exclude": "*.TMP\n",
+		".gitignore":        "Build/\n",
+	})
+
+	config, err := LoadConfig(root)
+	assert.NoError(test, err)
+	assert.Equal(test, filepath.Join(root, ".git"), config.GitDir())
+
+	r, err := NewRepository(root)
+	assert.NoError(test, err)
+	assert.True(test, r.MatchesPath("build/"), "build/ should match")
+	assert.True(test, r.MatchesPath("a.tmp"), "a.tmp should match")
+}
+
+func assertConfigValue(test *testing.T, config *Config, key, expected string) {
+	value, ok := config.Get(key)
+	assert.True(test, ok, key+" should be set")
+	assert.Equal(test, expected, value, key)
+}
+
+// Validate that a linked work tree, as created by `git worktree add`, reads
+// the config file of the main git directory
+func TestLoadConfigLinkedWorkTree(test *testing.T) {
+	isolateHomeForTest(test)
+	main := writeTreeToTestDir(test, map[string]string{
+		".git/config":                 "[core]\n\tignoreCase = true\n\texcludesFile = local-ignore\n",
+		".git/worktrees/wt/commondir": "../..\n",
+	})
+	root := writeTreeToTestDir(test, map[string]string{
+		"local-ignore": "*.local\n",
+	})
+	gitDir := filepath.Join(main, ".git", "worktrees", "wt")
+	writeFileToDir(test, root, ".git", "gitdir: "+filepath.ToSlash(gitDir)+"\n")
+
+	config, err := LoadConfig(root)
+	assert.NoError(test, err)
+	assert.Equal(test, gitDir, config.GitDir())
+	ignoreCase, _ := config.GetBool("core.ignoreCase")
+	assert.True(test, ignoreCase)
+
+	r, err := NewRepository(root)
+	assert.NoError(test, err)
+	assert.True(test, r.MatchesPath("A.LOCAL"), "A.LOCAL should match")
+}
@@ -0,0 +1,144 @@
+package ignore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadExcludes returns the exclude files git reads besides the .gitignore
+// files of the work tree at `root`, by increasing precedence:
+//  1. the file set by core.excludesFile in the global or local git config,
+//     which defaults to $XDG_CONFIG_HOME/git/ignore or ~/.config/git/ignore
+//  2. $GIT_DIR/info/exclude, from the main git directory for a linked
+//     work tree
+//
+// Their patterns are relative to `root`. Missing files are skipped. The git
+// config is read with LoadConfig, and WithIgnoreCase applies if
+// core.ignoreCase is true. The options apply to every file.
+func LoadExcludes(root string, opts ...Option) ([]*GitIgnore, error) {
+	config, err := LoadConfig(root)
+	if err != nil {
+		return nil, err
+	}
+	return loadExcludes(root, config, configOptions(config, opts))
+}
+
+// loadExcludes implements LoadExcludes with the git config already loaded
+// and the options already set from it.
+func loadExcludes(root string, config *Config, opts []Option) ([]*GitIgnore, error) {
+	fpaths := []string{excludesFile(root, config)}
+	if gitDir := config.GitDir(); gitDir != "" {
+		commonDir, err := findCommonDir(gitDir)
+		if err != nil {
+			return nil, err
+		}
+		fpaths = append(fpaths, filepath.Join(commonDir, "info", "exclude"))
+	}
+
+	var ignores []*GitIgnore
+	for _, fpath := range fpaths {
+		if fpath == "" {
+			continue
+		}
+		gi, err := CompileIgnoreFile(fpath, opts...)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		ignores = append(ignores, gi)
+	}
+	return ignores, nil
+}
+
+// findGitDir returns the git directory of the work tree at `root`, or an
+// empty string if there is none. The .git entry is either the directory
+// itself or, for linked work trees and submodules, a file pointing to it.
+// The git directory of a linked work tree only holds its own files, see
+// findCommonDir for the ones it shares with the main work tree.
+func findGitDir(root string) (string, error) {
+	dotGit := filepath.Join(root, ".git")
+	fi, err := os.Stat(dotGit)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if fi.IsDir() {
+		return dotGit, nil
+	}
+
+	buffer, err := ioutil.ReadFile(dotGit)
+	if err != nil {
+		return "", err
+	}
+	line := strings.TrimSpace(string(buffer))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return "", nil
+	}
+	gitDir := filepath.FromSlash(strings.TrimSpace(strings.TrimPrefix(line, "gitdir:")))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(root, gitDir)
+	}
+	return gitDir, nil
+}
+
+// findCommonDir returns the directory holding the files of the git
+// directory `gitDir` which are shared by all the work trees, such as
+// info/exclude and config. For a linked work tree, created by
+// `git worktree add`, it is set by the commondir file, relative to
+// `gitDir`. Otherwise it is `gitDir` itself.
+func findCommonDir(gitDir string) (string, error) {
+	buffer, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return gitDir, nil
+		}
+		return "", err
+	}
+	commonDir := filepath.FromSlash(strings.TrimSpace(string(buffer)))
+	if commonDir == "" {
+		return gitDir, nil
+	}
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return commonDir, nil
+}
+
+// excludesFile returns the path of the global exclude file, as set by
+// core.excludesFile, or an empty string if there is none.
+func excludesFile(root string, config *Config) string {
+	fpath, ok := config.GetPath("core.excludesFile")
+	if !ok {
+		if xdg := xdgConfigHome(); xdg != "" {
+			return filepath.Join(xdg, "git", "ignore")
+		}
+		return ""
+	}
+	if fpath == "" {
+		return ""
+	}
+
+	// Relative paths are relative to the work tree
+	fpath = filepath.FromSlash(fpath)
+	if !filepath.IsAbs(fpath) {
+		fpath = filepath.Join(root, fpath)
+	}
+	return fpath
+}
+
+// configOptions returns the options matching the settings of the git
+// config, followed by `opts`. It sets WithIgnoreCase if core.ignoreCase
+// is true.
+func configOptions(config *Config, opts []Option) []Option {
+	var configOpts []Option
+	if ignoreCase, _ := config.GetBool("core.ignoreCase"); ignoreCase {
+		configOpts = append(configOpts, WithIgnoreCase())
+	}
+	return append(configOpts, opts...)
+}
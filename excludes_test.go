@@ -0,0 +1,130 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setenvForTest is a helper function to set an environment variable for
+// the duration of the test
+func setenvForTest(test *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		test.Fatalf("failed to set %s: %s", key, err)
+	}
+	test.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// isolateHomeForTest is a helper function which points the home directory
+// of the test to an empty temp directory, so the git config and exclude
+// files of the user are not read. It returns the home directory.
+func isolateHomeForTest(test *testing.T) string {
+	home := test.TempDir()
+	setenvForTest(test, "HOME", home)
+	setenvForTest(test, "USERPROFILE", home)
+	setenvForTest(test, "XDG_CONFIG_HOME", "")
+	return home
+}
+
+// writeFileToDir is a helper function to write to the file "fname" within
+// "dir" with the content "content", creating its parent directories
+func writeFileToDir(test *testing.T, dir, fname, content string) {
+	writeTree(test, dir, map[string]string{fname: content})
+}
+
+func TestLoadExcludes(test *testing.T) {
+	home := isolateHomeForTest(test)
+	writeFileToDir(test, home, ".config/git/ignore", "*.bak\n*.swp\n")
+	root := writeTreeToTestDir(test, map[string]string{
+		".git/info/exclude": "!keep.bak\n/local/\n",
+		".gitignore":        "!*.swp\n",
+	})
+
+	ignores, err := LoadExcludes(root)
+	assert.NoError(test, err)
+	if assert.Len(test, ignores, 2) {
+		assert.True(test, ignores[0].MatchesPath("a.bak"), "a.bak should match")
+		assert.True(test, ignores[1].MatchesPath("local/"), "local/ should match")
+	}
+
+	r, err := NewRepository(root)
+	assert.NoError(test, err)
+	assert.True(test, r.MatchesPath("x/a.bak"), "x/a.bak should match")
+	assert.False(test, r.MatchesPath("x/keep.bak"), "x/keep.bak should not match")
+	assert.True(test, r.MatchesPath("local/a"), "local/a should match")
+	assert.False(test, r.MatchesPath("a.swp"), "a.swp should not match, .gitignore takes precedence")
+
+	result := r.Match("a.bak")
+	if assert.NotNil(test, result) {
+		assert.Equal(test, filepath.Join(home, ".config", "git", "ignore"), result.Source)
+	}
+}
+
+func TestLoadExcludesConfig(test *testing.T) {
+	home := isolateHomeForTest(test)
+	xdg := filepath.Join(home, "xdg")
+	setenvForTest(test, "XDG_CONFIG_HOME", xdg)
+	writeFileToDir(test, xdg, "git/ignore", "*.xdg\n")
+	writeFileToDir(test, home, "global-ignore", "*.global\n")
+	writeFileToDir(test, home, "local-ignore", "*.local\n")
+
+	root := writeTreeToTestDir(test, map[string]string{
+		"sub/": "",
+	})
+
+	// Defaults to $XDG_CONFIG_HOME/git/ignore
+	r, err := NewRepository(root)
+	assert.NoError(test, err)
+	assert.True(test, r.MatchesPath("a.xdg"), "a.xdg should match")
+
+	// Set by the global config
+	writeFileToDir(test, home, ".gitconfig", `
+[user]
+	excludesFile = wrong-section
+[core]
+	autocrlf = false
+	excludesFile = "~/global-ignore" ; comment
+`)
+	r, err = NewRepository(root)
+	assert.NoError(test, err)
+	assert.False(test, r.MatchesPath("a.xdg"), "a.xdg should not match")
+	assert.True(test, r.MatchesPath("a.global"), "a.global should match")
+
+	// Overridden by the local config, through a .git file
+	gitDir := filepath.Join(home, "gitdir")
+	writeFileToDir(test, gitDir, "config", "[core]\n\texcludesfile = "+filepath.ToSlash(filepath.Join(home, "local-ignore"))+"\n")
+	writeFileToDir(test, root, ".git", "gitdir: "+filepath.ToSlash(gitDir)+"\n")
+	r, err = NewRepository(root)
+	assert.NoError(test, err)
+	assert.False(test, r.MatchesPath("a.global"), "a.global should not match")
+	assert.True(test, r.MatchesPath("sub/a.local"), "sub/a.local should match")
+}
+
+// Validate that a linked work tree, as created by `git worktree add`, reads
+// the info/exclude file of the main git directory
+func TestLoadExcludesLinkedWorkTree(test *testing.T) {
+	isolateHomeForTest(test)
+	main := writeTreeToTestDir(test, map[string]string{
+		".git/info/exclude":           "*.secret\n",
+		".git/worktrees/wt/HEAD":      "",
+		".git/worktrees/wt/commondir": "../..\n",
+	})
+	root := writeTreeToTestDir(test, map[string]string{
+		"a.secret": "",
+	})
+	writeFileToDir(test, root, ".git", "gitdir: "+filepath.ToSlash(filepath.Join(main, ".git", "worktrees", "wt"))+"\n")
+
+	r, err := NewRepository(root)
+	assert.NoError(test, err)
+	assert.True(test, r.MatchesPath("a.secret"), "a.secret should match")
+	assert.Equal(test, []string{"./"}, walkForTest(test, root))
+}
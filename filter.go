@@ -0,0 +1,223 @@
+package ignore
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+)
+
+// filterChunk is the number of paths matched at once by a goroutine of
+// FilterPaths or FilterChan.
+const filterChunk = 256
+
+// WithFilterWorkers makes FilterPaths and FilterChan match the paths with
+// up to `n` goroutines. By default, FilterPaths matches them in the calling
+// goroutine and FilterChan in a single one.
+func WithFilterWorkers(n int) Option {
+	return func(gi *GitIgnore) {
+		gi.filterWorkers = n
+	}
+}
+
+// FilterPaths splits `paths` into the paths which are not ignored and the
+// ones which are, each in the given order. A trailing slash marks a
+// directory, as for MatchesPath. The decisions made for the directories of
+// a path are reused for the next one, which makes sorted paths, such as
+// the output of `git ls-files`, faster to match.
+func (gi *GitIgnore) FilterPaths(paths []string) (kept, ignored []string) {
+	flags := make([]bool, len(paths))
+	workers := gi.filterWorkers
+	if n := (len(paths) + filterChunk - 1) / filterChunk; workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		gi.matchPaths(paths, flags)
+	} else {
+		// Give each goroutine a contiguous part, which keeps the paths
+		// of a directory together
+		var wg sync.WaitGroup
+		size := (len(paths) + workers - 1) / workers
+		for start := 0; start < len(paths); start += size {
+			end := start + size
+			if end > len(paths) {
+				end = len(paths)
+			}
+			wg.Add(1)
+			go func(start, end int) {
+				defer wg.Done()
+				gi.matchPaths(paths[start:end], flags[start:end])
+			}(start, end)
+		}
+		wg.Wait()
+	}
+
+	for i, f := range paths {
+		if flags[i] {
+			ignored = append(ignored, f)
+		} else {
+			kept = append(kept, f)
+		}
+	}
+	return kept, ignored
+}
+
+// FilterChan sends the paths received from `in` which are not ignored to
+// the returned channel, in the same order, like FilterPaths. The channel
+// is closed once `in` is closed or `ctx` is done.
+func (gi *GitIgnore) FilterChan(ctx context.Context, in <-chan string) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		if gi.filterWorkers <= 1 {
+			m := &prefixMatcher{gi: gi}
+			for {
+				chunk, ok := readChunk(ctx, in)
+				if !sendKept(ctx, out, chunk, m) || !ok {
+					return
+				}
+			}
+		}
+
+		// Match the chunks concurrently, and send them in order. The
+		// buffer of results bounds the number of chunks in flight.
+		results := make(chan chan []string, gi.filterWorkers-1)
+		go func() {
+			defer close(results)
+			for {
+				chunk, ok := readChunk(ctx, in)
+				if len(chunk) > 0 {
+					res := make(chan []string, 1)
+					select {
+					case results <- res:
+					case <-ctx.Done():
+						return
+					}
+					go func() {
+						kept, _ := gi.FilterPaths(chunk)
+						res <- kept
+					}()
+				}
+				if !ok {
+					return
+				}
+			}
+		}()
+		for res := range results {
+			if !sendKept(ctx, out, <-res, nil) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// readChunk reads up to filterChunk paths from `in`, only waiting for the
+// first one. It returns false once `in` is closed or `ctx` is done.
+func readChunk(ctx context.Context, in <-chan string) ([]string, bool) {
+	var chunk []string
+	select {
+	case f, ok := <-in:
+		if !ok {
+			return nil, false
+		}
+		chunk = append(chunk, f)
+	case <-ctx.Done():
+		return nil, false
+	}
+	for len(chunk) < filterChunk {
+		select {
+		case f, ok := <-in:
+			if !ok {
+				return chunk, false
+			}
+			chunk = append(chunk, f)
+		default:
+			return chunk, true
+		}
+	}
+	return chunk, true
+}
+
+// sendKept sends the paths of `chunk` to `out`, skipping the ones ignored
+// according to `m`, or all of them if `m` is nil. It returns false if
+// `ctx` is done.
+func sendKept(ctx context.Context, out chan<- string, chunk []string, m *prefixMatcher) bool {
+	for _, f := range chunk {
+		if m != nil && m.matchesPath(f) {
+			continue
+		}
+		select {
+		case out <- f:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// matchPaths sets `ignored[i]` to true if `paths[i]` is ignored.
+func (gi *GitIgnore) matchPaths(paths []string, ignored []bool) {
+	m := &prefixMatcher{gi: gi}
+	for i, f := range paths {
+		ignored[i] = m.matchesPath(f)
+	}
+}
+
+// prefixMatcher matches paths one after the other, remembering the
+// decisions made for the directories of the last one, so that the next
+// one only has to match the directories which differ, and itself.
+type prefixMatcher struct {
+	gi *GitIgnore
+	// dir is the parent directory of the last path, cleaned
+	dir string
+	// ends are the lengths of `dir` and of its parents, from the top one
+	ends []int
+	// bests are the decisions made for the directories in `ends`
+	bests []int
+}
+
+// matchesPath is like MatchesPath.
+func (m *prefixMatcher) matchesPath(f string) bool {
+	isDir := strings.HasSuffix(f, "/") || strings.HasSuffix(f, string(os.PathSeparator))
+	f, ok := m.gi.relPath(f)
+	if !ok {
+		return false
+	}
+	dir := ""
+	if i := strings.LastIndexByte(f, '/'); i >= 0 {
+		dir = f[:i]
+	}
+
+	// Keep the decisions made for the directories in common
+	c := 0
+	for c < len(dir) && c < len(m.dir) && dir[c] == m.dir[c] {
+		c++
+	}
+	n := 0
+	for n < len(m.ends) && m.ends[n] <= c && (m.ends[n] == len(dir) || dir[m.ends[n]] == '/') {
+		n++
+	}
+	m.ends, m.bests = m.ends[:n], m.bests[:n]
+
+	parent := -1
+	start := 0
+	if n > 0 {
+		parent = m.bests[n-1]
+		start = m.ends[n-1] + 1
+	}
+	for i := start; dir != "" && i <= len(dir); i++ {
+		if i == len(dir) || dir[i] == '/' {
+			parent = m.gi.decide(dir[:i], true, parent)
+			m.ends = append(m.ends, i)
+			m.bests = append(m.bests, parent)
+		}
+	}
+	m.dir = dir
+
+	if !m.gi.index.negated && parent >= 0 {
+		return true
+	}
+	best := m.gi.decide(f, isDir, parent)
+	return best >= 0 && !m.gi.patterns[best].negate
+}
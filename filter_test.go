@@ -0,0 +1,180 @@
+package ignore
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterPaths(test *testing.T) {
+	object := CompileIgnoreLines("build/", "*.log", "!keep.log", "/docs/*", "!docs/README.md")
+
+	kept, ignored := object.FilterPaths([]string{
+		"build/a.go",
+		"build/sub/b.go",
+		"docs/README.md",
+		"docs/guide.md",
+		"main.go",
+		"src/build/c.go",
+		"src/debug.log",
+		"src/keep.log",
+		"src/lib/",
+	})
+	assert.Equal(test, []string{"docs/README.md", "main.go", "src/keep.log", "src/lib/"}, kept)
+	assert.Equal(test, []string{"build/a.go", "build/sub/b.go", "docs/guide.md", "src/build/c.go", "src/debug.log"}, ignored)
+
+	kept, ignored = object.FilterPaths(nil)
+	assert.Empty(test, kept)
+	assert.Empty(test, ignored)
+}
+
+// randomPaths returns `n` paths made of a few names, sorted if `sorted` is
+// true, some of them marked as directories
+func randomPaths(rng *rand.Rand, n int, sorted bool) []string {
+	names := []string{"a", "b", "A", "x.y", "x", "."}
+	var paths []string
+	for i := 0; i < n; i++ {
+		var p strings.Builder
+		for m := rng.Intn(5) + 1; m > 0; m-- {
+			if p.Len() > 0 {
+				p.WriteByte('/')
+			}
+			p.WriteString(names[rng.Intn(len(names))])
+		}
+		if rng.Intn(4) == 0 {
+			p.WriteByte('/')
+		}
+		paths = append(paths, p.String())
+	}
+	if sorted {
+		sort.Strings(paths)
+	}
+	return paths
+}
+
+// Validate that sharing the decisions between paths does not change them
+func TestFilterPathsRandom(test *testing.T) {
+	pieces := []string{"a", "b", ".", "/", "*", "?", "**", "A", "!", "x/"}
+	rng := rand.New(rand.NewSource(4))
+	options := [][]Option{
+		nil,
+		{WithParentExclusion()},
+		{WithIgnoreCase()},
+		{WithBase("x")},
+		{WithFilterWorkers(4)},
+	}
+
+	for i := 0; i < 300; i++ {
+		var lines []string
+		for n := rng.Intn(8) + 1; n > 0; n-- {
+			var p strings.Builder
+			for m := rng.Intn(3) + 1; m > 0; m-- {
+				p.WriteString(pieces[rng.Intn(len(pieces))])
+			}
+			lines = append(lines, p.String())
+		}
+		object := New(options[rng.Intn(len(options))]...).AddPatternsFromLines(lines...)
+		paths := randomPaths(rng, rng.Intn(1000), rng.Intn(2) == 0)
+
+		var expectedKept, expectedIgnored []string
+		for _, f := range paths {
+			if object.MatchesPath(f) {
+				expectedIgnored = append(expectedIgnored, f)
+			} else {
+				expectedKept = append(expectedKept, f)
+			}
+		}
+		kept, ignored := object.FilterPaths(paths)
+		if !assert.Equal(test, expectedKept, kept, "%q", lines) {
+			return
+		}
+		assert.Equal(test, expectedIgnored, ignored, "%q", lines)
+	}
+}
+
+func TestFilterChan(test *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	paths := randomPaths(rng, 3000, true)
+
+	for _, workers := range []int{0, 1, 4} {
+		object := New(WithFilterWorkers(workers)).AddPatternsFromLines("a/b", "*.y", "!x/x.y", "A/")
+		expected, _ := object.FilterPaths(paths)
+
+		in := make(chan string)
+		go func() {
+			defer close(in)
+			for _, f := range paths {
+				in <- f
+			}
+		}()
+		var kept []string
+		for f := range object.FilterChan(context.Background(), in) {
+			kept = append(kept, f)
+		}
+		assert.Equal(test, expected, kept, "%d workers", workers)
+	}
+}
+
+func TestFilterChanCanceled(test *testing.T) {
+	for _, workers := range []int{0, 4} {
+		object := New(WithFilterWorkers(workers))
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// Neither `in` is closed, nor is the output read after the first
+		// path: the output is still closed once canceled
+		in := make(chan string, 10)
+		for i := 0; i < 10; i++ {
+			in <- fmt.Sprint(i)
+		}
+		out := object.FilterChan(ctx, in)
+		assert.Equal(test, "0", <-out)
+		cancel()
+		for range out {
+		}
+	}
+}
+
+// lsFilesPaths is a sorted list of paths, as listed by `git ls-files`
+var lsFilesPaths = func() []string {
+	var paths []string
+	for _, dir := range []string{"cmd/tool", "docs/api", "internal/pkg/util", "node_modules/lib/dist", "src/app/views"} {
+		for i := 0; i < 2000; i++ {
+			paths = append(paths, fmt.Sprintf("%s/sub%d/file%d.go", dir, i/100, i))
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}()
+
+func BenchmarkMatchesPathLsFiles(b *testing.B) {
+	object := CompileIgnoreLines(templateLines()...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, f := range lsFilesPaths {
+			object.MatchesPath(f)
+		}
+	}
+}
+
+func benchmarkFilterPaths(b *testing.B, opts ...Option) {
+	object := New(opts...).AddPatternsFromLines(templateLines()...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		object.FilterPaths(lsFilesPaths)
+	}
+}
+
+func BenchmarkFilterPaths(b *testing.B) {
+	benchmarkFilterPaths(b)
+}
+
+func BenchmarkFilterPathsWorkers(b *testing.B) {
+	benchmarkFilterPaths(b, WithFilterWorkers(4))
+}
@@ -0,0 +1,169 @@
+package ignore
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+)
+
+// FilterFS returns a file system which hides the files and directories of
+// `fsys` matched by `gi`, along with everything inside of the ignored
+// directories. Its Open, ReadDir, Stat and Glob methods, and the ReadDir
+// method of its directories, behave as if the ignored entries did not
+// exist, returning fs.ErrNotExist for them. The paths given to `gi` are
+// relative to the root of `fsys`.
+func FilterFS(fsys fs.FS, gi IgnoreParser) fs.FS {
+	return &filterFS{fsys: fsys, gi: gi}
+}
+
+// filterFS is the file system returned by FilterFS.
+type filterFS struct {
+	fsys fs.FS
+	gi   IgnoreParser
+}
+
+var (
+	_ fs.ReadDirFS = (*filterFS)(nil)
+	_ fs.StatFS    = (*filterFS)(nil)
+	_ fs.GlobFS    = (*filterFS)(nil)
+)
+
+// Open opens the file `name` of the underlying file system, unless it is
+// ignored.
+func (f *filterFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if f.parentIgnored(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	file, err := f.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if name != "." && f.gi.MatchesPathIsDir(name, fi.IsDir()) {
+		file.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if fi.IsDir() {
+		return &filterDir{File: file, fs: f, name: name}, nil
+	}
+	return file, nil
+}
+
+// ReadDir reads the directory `name` of the underlying file system,
+// unless it is ignored, and returns its entries which are not ignored.
+func (f *filterFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if f.parentIgnored(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	// Only a directory can be matched as such, the underlying file system
+	// reports the error for anything else
+	if name != "." {
+		if fi, err := fs.Stat(f.fsys, name); err == nil && fi.IsDir() && f.gi.MatchesPathIsDir(name, true) {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+		}
+	}
+
+	entries, err := fs.ReadDir(f.fsys, name)
+	return f.filterEntries(name, entries), err
+}
+
+// Stat returns the file info of the file `name` of the underlying file
+// system, unless it is ignored.
+func (f *filterFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if f.parentIgnored(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	fi, err := fs.Stat(f.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if name != "." && f.gi.MatchesPathIsDir(name, fi.IsDir()) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return fi, nil
+}
+
+// Glob returns the names of the files of the underlying file system
+// matching `pattern`, as fs.Glob does, which are not ignored.
+func (f *filterFS) Glob(pattern string) ([]string, error) {
+	names, err := fs.Glob(f.fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := names[:0]
+	for _, name := range names {
+		if _, err := f.Stat(name); err == nil {
+			kept = append(kept, name)
+		}
+	}
+	return kept, nil
+}
+
+// parentIgnored returns true if one of the parent directories of the path
+// `name` is ignored.
+func (f *filterFS) parentIgnored(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] == '/' && f.gi.MatchesPathIsDir(name[:i], true) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterEntries removes the ignored entries of the directory `dir` from
+// `entries`, which it modifies in place.
+func (f *filterFS) filterEntries(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	kept := entries[:0]
+	for _, e := range entries {
+		name := e.Name()
+		if dir != "." {
+			name = path.Join(dir, name)
+		}
+		if !f.gi.MatchesPathIsDir(name, e.IsDir()) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// filterDir is an open directory of a filterFS, whose ReadDir method only
+// returns the entries which are not ignored.
+type filterDir struct {
+	fs.File
+	fs   *filterFS
+	name string
+}
+
+// ReadDir reads the entries of the directory which are not ignored, as
+// fs.ReadDirFile does.
+func (d *filterDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rd, ok := d.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: errors.New("not implemented")}
+	}
+	for {
+		entries, err := rd.ReadDir(n)
+		entries = d.fs.filterEntries(d.name, entries)
+
+		// Only report that there are no entries left at the end
+		if n <= 0 || len(entries) > 0 || err != nil {
+			return entries, err
+		}
+	}
+}
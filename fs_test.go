@@ -0,0 +1,177 @@
+package ignore
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newMapFSForTest is a helper function returning an in-memory file system
+// holding the files of "tree", which maps paths to contents.
+func newMapFSForTest(tree map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for name, content := range tree {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	return fsys
+}
+
+func TestFilterFS(test *testing.T) {
+	fsys := newMapFSForTest(map[string]string{
+		".gitignore":           "*.log\n/build/\n!keep.log\ncache/\n",
+		"main.go":              "package main",
+		"debug.log":            "",
+		"keep.log":             "kept",
+		"build/out":            "",
+		"build/keep.log":       "",
+		"src/lib.go":           "",
+		"src/build/file.go":    "",
+		"src/cache/data":       "",
+		"src/cache/keep.log":   "",
+		"src/nested/debug.log": "",
+	})
+	gi := CompileIgnoreLines("*.log", "/build/", "!keep.log", "cache/")
+	filtered := FilterFS(fsys, gi)
+
+	if err := fstest.TestFS(filtered, ".gitignore", "main.go", "keep.log", "src/lib.go", "src/build/file.go", "src/nested"); err != nil {
+		test.Fatal(err)
+	}
+
+	for _, name := range []string{"debug.log", "build", "build/out", "build/keep.log", "src/cache", "src/cache/data", "src/cache/keep.log", "src/nested/debug.log"} {
+		_, err := filtered.Open(name)
+		assert.True(test, errors.Is(err, fs.ErrNotExist), "%s should not exist when opened", name)
+		_, err = fs.Stat(filtered, name)
+		assert.True(test, errors.Is(err, fs.ErrNotExist), "%s should not exist when stat'd", name)
+	}
+	_, err := fs.ReadDir(filtered, "build")
+	assert.True(test, errors.Is(err, fs.ErrNotExist), "build should not exist when read")
+	_, err = fs.ReadDir(filtered, "src/cache")
+	assert.True(test, errors.Is(err, fs.ErrNotExist), "src/cache should not exist when read")
+
+	data, err := fs.ReadFile(filtered, "keep.log")
+	assert.NoError(test, err)
+	assert.Equal(test, "kept", string(data))
+
+	entries, err := fs.ReadDir(filtered, ".")
+	assert.NoError(test, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.Equal(test, []string{".gitignore", "keep.log", "main.go", "src"}, names)
+
+	matches, err := fs.Glob(filtered, "*.log")
+	assert.NoError(test, err)
+	assert.Equal(test, []string{"keep.log"}, matches)
+	matches, err = fs.Glob(filtered, "src/*/*")
+	assert.NoError(test, err)
+	assert.Equal(test, []string{"src/build/file.go"}, matches)
+	_, err = fs.Glob(filtered, "[")
+	assert.Equal(test, path.ErrBadPattern, err)
+
+	var walked []string
+	err = fs.WalkDir(filtered, ".", func(name string, d fs.DirEntry, err error) error {
+		walked = append(walked, name)
+		return err
+	})
+	assert.NoError(test, err)
+	assert.Equal(test, []string{".", ".gitignore", "keep.log", "main.go", "src", "src/build", "src/build/file.go", "src/lib.go", "src/nested"}, walked)
+}
+
+func TestFilterFSReadDirFile(test *testing.T) {
+	fsys := newMapFSForTest(map[string]string{
+		"a.log": "",
+		"b.log": "",
+		"c.txt": "",
+		"d.log": "",
+		"e.txt": "",
+	})
+	filtered := FilterFS(fsys, CompileIgnoreLines("*.log"))
+
+	f, err := filtered.Open(".")
+	assert.NoError(test, err)
+	defer f.Close()
+	dir, ok := f.(fs.ReadDirFile)
+	assert.True(test, ok, "directory should be a fs.ReadDirFile")
+
+	var names []string
+	for {
+		entries, err := dir.ReadDir(1)
+		if err != nil {
+			assert.Empty(test, entries)
+			break
+		}
+		assert.Len(test, entries, 1)
+		names = append(names, entries[0].Name())
+	}
+	assert.Equal(test, []string{"c.txt", "e.txt"}, names)
+}
+
+// Validate that a directory-only pattern does not hide a file when it is
+// read as a directory
+func TestFilterFSReadDirNotADirectory(test *testing.T) {
+	fsys := newMapFSForTest(map[string]string{
+		"foo":       "",
+		"bar/a.txt": "",
+	})
+	filtered := FilterFS(fsys, CompileIgnoreLines("foo/", "bar/"))
+
+	_, err := fs.Stat(filtered, "foo")
+	assert.NoError(test, err)
+	_, err = fs.ReadDir(filtered, "foo")
+	_, expected := fs.ReadDir(fsys, "foo")
+	assert.Error(test, err)
+	assert.False(test, errors.Is(err, fs.ErrNotExist), "foo should exist when read")
+	assert.Equal(test, expected, err)
+
+	_, err = fs.ReadDir(filtered, "bar")
+	assert.True(test, errors.Is(err, fs.ErrNotExist), "bar should not exist when read")
+}
+
+func TestFilterFSInvalidPath(test *testing.T) {
+	filtered := FilterFS(fstest.MapFS{}, CompileIgnoreLines("*.log"))
+	_, err := filtered.Open("../x")
+	assert.True(test, errors.Is(err, fs.ErrInvalid), "../x should be invalid")
+	_, err = fs.Stat(filtered, "/x")
+	assert.True(test, errors.Is(err, fs.ErrInvalid), "/x should be invalid")
+	_, err = fs.ReadDir(filtered, "x/")
+	assert.True(test, errors.Is(err, fs.ErrInvalid), "x/ should be invalid")
+}
+
+func TestFilterFSRepository(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeTreeToTestDir(test, map[string]string{
+		".gitignore":      "*.log\n",
+		"index.html":      "index",
+		"debug.log":       "secret",
+		"docs/.gitignore": "/private/\n",
+		"docs/private/a":  "secret",
+		"docs/public/a":   "public",
+	})
+	r, err := NewRepository(root)
+	assert.NoError(test, err)
+	filtered := FilterFS(os.DirFS(root), r)
+
+	server := httptest.NewServer(http.FileServer(http.FS(filtered)))
+	defer server.Close()
+	for name, status := range map[string]int{
+		"/index.html":     http.StatusOK,
+		"/docs/public/a":  http.StatusOK,
+		"/debug.log":      http.StatusNotFound,
+		"/docs/private/a": http.StatusNotFound,
+		"/docs/private/":  http.StatusNotFound,
+	} {
+		resp, err := http.Get(server.URL + name)
+		if assert.NoError(test, err) {
+			resp.Body.Close()
+			assert.Equal(test, status, resp.StatusCode, name)
+		}
+	}
+}
@@ -52,201 +52,778 @@ The summarized version of the same has been copied here:
 package ignore
 
 import (
-	"io/ioutil"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
-	"regexp"
+	"path"
 	"strings"
-)
-
-const magicStar = "#$~"
-
-var (
-	// Pre-compile regexes for getPatternFromLine
-	a = regexp.MustCompile(`^(\#|\!)`)
-	b = regexp.MustCompile(`([^\/+])/.*\*\.`)
-	c = regexp.MustCompile(`\.`)
-	d = regexp.MustCompile(`/\*\*/`)
-	e = regexp.MustCompile(`\*\*/`)
-	f = regexp.MustCompile(`/\*\*`)
-	g = regexp.MustCompile(`\\\*`)
-	h = regexp.MustCompile(`\*`)
+	"unsafe"
 )
 
 ////////////////////////////////////////////////////////////
 
-// IgnoreParser is an interface which exposes a single method:
-//   MatchesPath() - Returns true if the path is targeted by the patterns compiled
-//                   in the GitIgnore structure
+// IgnoreParser is an interface which exposes two methods:
+//   MatchesPath()      - Returns true if the path is targeted by the patterns compiled
+//                        in the GitIgnore structure
+//   MatchesPathIsDir() - Same as MatchesPath(), but the caller states whether
+//                        the path is a directory
 type IgnoreParser interface {
 	MatchesPath(f string) bool
+	MatchesPathIsDir(f string, isDir bool) bool
+}
+
+// ParseError describes a line of an ignore file which is not a valid
+// pattern.
+type ParseError struct {
+	Source string // the file the line was read from, if any
+	Line   int    // the 1-based line number
+	Column int    // the 1-based column of the offending character
+	Reason string
+}
+
+func (pe *ParseError) Error() string {
+	source := pe.Source
+	if source == "" {
+		source = "<lines>"
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", source, pe.Line, pe.Column, pe.Reason)
+}
+
+// ParseErrors is the list of errors returned by CompileIgnoreLinesE and
+// CompileIgnoreFileE, in the order of the offending lines.
+type ParseErrors []*ParseError
+
+func (pe ParseErrors) Error() string {
+	msgs := make([]string, len(pe))
+	for i, e := range pe {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// FileErrors is the list of errors returned by AddPatternsFromFilesE and
+// AddPatternsFromFSE, in the order of the files. It holds a *fs.PathError
+// for each file which cannot be read, and a *ParseError for each line
+// which is not a valid pattern.
+type FileErrors []error
+
+func (fe FileErrors) Error() string {
+	msgs := make([]string, len(fe))
+	for i, e := range fe {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
 }
 
 // getPatternFromLine pretty much attempts to mimic the parsing rules
-// listed above at the start of this file
-func getPatternFromLine(line string) (*regexp.Regexp, bool) {
+// listed above at the start of this file. Lines which are not valid
+// patterns are reported with a *ParseError, whose Source and Line are
+// left to the caller. The pattern is still returned if it can be used.
+// It matches regardless of case if `ignoreCase` is true.
+func getPatternFromLine(line string, ignoreCase bool) (*ignorePattern, *ParseError) {
 	// Trim OS-specific carriage returns.
 	line = strings.TrimRight(line, "\r")
 
 	// Strip comments [Rule 2]
 	if strings.HasPrefix(line, `#`) {
-		return nil, false
+		return nil, nil
 	}
 
-	// Trim string [Rule 3]
-	// TODO: Handle [Rule 3], when the " " is escaped with a \
-	line = strings.Trim(line, " ")
+	// Trim trailing spaces [Rule 3], leading spaces are significant
+	line = trimTrailingSpaces(line)
+	column := 1
 
 	// Exit for no-ops and return nil which will prevent us from
 	// appending a pattern against this line
 	if line == "" {
-		return nil, false
+		return nil, nil
 	}
+	text := line
 
-	// TODO: Handle [Rule 4] which negates the match for patterns leading with "!"
+	// Handle [Rule 4] which negates the match for patterns leading with "!"
 	negatePattern := false
 	if line[0] == '!' {
 		negatePattern = true
 		line = line[1:]
+		column++
+	}
+
+	// Handle [Rule 5], a trailing / only matches directories
+	dirOnly := false
+	if strings.HasSuffix(line, "/") {
+		// Like git, only strip one, "foo//" matches nothing
+		dirOnly = true
+		line = line[:len(line)-1]
 	}
 
-	// Handle [Rule 2, 4], when # or ! is escaped with a \
-	// Handle [Rule 4] once we tag negatePattern, strip the leading ! char
-	if a.MatchString(line) {
+	// Handle [Rule 6, 7, 8], a / at the beginning or in the middle of the
+	// pattern makes it relative to the directory of the ignore file,
+	// otherwise it can match at any directory level
+	anchored := strings.Contains(line, "/")
+	if strings.HasPrefix(line, "/") {
 		line = line[1:]
+		column++
+	}
+	if line == "" {
+		return nil, nil
 	}
 
-	// If we encounter a foo/*.blah in a folder, prepend the / char
-	if b.MatchString(line) && line[0] != '/' {
-		line = "/" + line
+	// Escaped characters, including a leading \# or \! [Rule 2, 4], are
+	// taken care of by the tokenizer
+	tokens, perr := tokenize(line)
+	if perr != nil {
+		perr.Column += column - 1
+		if tokens == nil {
+			// git never matches an invalid pattern
+			return nil, perr
+		}
 	}
 
-	// Handle escaping the "." char
-	line = c.ReplaceAllString(line, `\.`)
+	kind, literal := classify(tokens, anchored)
+	return &ignorePattern{
+		tokens:     tokens,
+		kind:       kind,
+		literal:    literal,
+		prefix:     leadingLiteral(tokens),
+		suffix:     trailingLiteral(tokens),
+		anchored:   anchored,
+		ignoreCase: ignoreCase,
+		negate:     negatePattern,
+		dirOnly:    dirOnly,
+		text:       text,
+	}, perr
+}
 
-	// Handle "/**/" usage
-	if strings.HasPrefix(line, "/**/") {
-		line = line[1:]
+// trimTrailingSpaces strips the trailing spaces of `line` unless they are
+// escaped with a \, the same way git does.
+func trimTrailingSpaces(line string) string {
+	end := len(line)
+	lastSpace := -1
+	for i := 0; i < end; i++ {
+		switch line[i] {
+		case ' ':
+			if lastSpace < 0 {
+				lastSpace = i
+			}
+		case '\\':
+			i++
+			if i == end {
+				return line
+			}
+			fallthrough
+		default:
+			lastSpace = -1
+		}
 	}
-	line = d.ReplaceAllString(line, `(/|/.+/)`)
-	line = e.ReplaceAllString(line, `(|.`+magicStar+`/)`)
-	line = f.ReplaceAllString(line, `(|/.`+magicStar+`)`)
+	if lastSpace >= 0 {
+		return line[:lastSpace]
+	}
+	return line
+}
 
-	// Handle escaping the "*" char
-	line = g.ReplaceAllString(line, `\`+magicStar)
-	line = h.ReplaceAllString(line, `([^/]*)`)
+// ignorePattern encapsulates a pattern, if it is a negated pattern and
+// if it only applies to directories, along with where it was defined.
+type ignorePattern struct {
+	tokens     []token // matched against a single path (see matches)
+	kind       patternKind
+	literal    string // the literal of a pattern which is not kindGeneral
+	prefix     string // the literal the matched paths start with, if any
+	suffix     string // the literal the matched paths end with, if any
+	anchored   bool
+	ignoreCase bool
+	negate     bool
+	dirOnly    bool
+
+	text   string // the pattern as written in the source
+	source string // the file the pattern was read from, if any
+	lineNo int    // the 1-based line number within the source
+}
 
-	// Handle escaping the "?" char
-	line = strings.Replace(line, "?", `\?`, -1)
+// addPatterns converts the lines read from `source` into patterns,
+// skipping blank lines and comments, and appends them to the GitIgnore
+// object. It returns the errors found on the way, which are nil if every
+// line is valid.
+func (gi *GitIgnore) addPatterns(source string, lines []string) ParseErrors {
+	defer gi.ClearCache()
+	var errs ParseErrors
+	for i, line := range lines {
+		if perr := gi.addPattern(source, i+1, line); perr != nil {
+			errs = append(errs, perr)
+		}
+	}
+	return errs
+}
 
-	line = strings.Replace(line, magicStar, "*", -1)
+// addPatternsFromReader is like addPatterns, but streams the lines from
+// `r`, skipping the UTF-8 byte order mark at its start. It also returns
+// the error of reading `r`, if any.
+func (gi *GitIgnore) addPatternsFromReader(r io.Reader, source string) (ParseErrors, error) {
+	defer gi.ClearCache()
+	var errs ParseErrors
+	reader := bufio.NewReader(r)
+	for lineNo := 1; ; lineNo++ {
+		// Unlike bufio.Scanner, lines are not limited in length
+		line, err := reader.ReadString('\n')
+		if line == "" && err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return errs, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		line = strings.TrimSuffix(line, "\r")
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if perr := gi.addPattern(source, lineNo, line); perr != nil {
+			errs = append(errs, perr)
+		}
+		if err != nil && err != io.EOF {
+			return errs, err
+		}
+	}
+}
 
-	// Temporary regex
-	var expr = ""
-	if strings.HasSuffix(line, "/") {
-		expr = line + "(|.*)$"
-	} else {
-		expr = line + "(|/.*)$"
+// addPattern converts the line `lineNo` read from `source` into a pattern
+// and appends it to the GitIgnore object, unless it is blank or a comment.
+// It returns the error found on the way, if any.
+func (gi *GitIgnore) addPattern(source string, lineNo int, line string) *ParseError {
+	ip, perr := getPatternFromLine(line, gi.ignoreCase)
+	if perr != nil {
+		perr.Source = source
+		perr.Line = lineNo
 	}
-	if strings.HasPrefix(expr, "/") {
-		expr = "^(|/)" + expr[1:]
-	} else {
-		expr = "^(|.*/)" + expr
+	if ip != nil {
+		ip.source = source
+		ip.lineNo = lineNo
+		gi.index.add(len(gi.patterns), ip, gi.ignoreCase)
+		gi.patterns = append(gi.patterns, ip)
 	}
-	pattern, _ := regexp.Compile(expr)
-
-	return pattern, negatePattern
+	return perr
 }
 
-// ignorePattern encapsulates a pattern and if it is a negated pattern.
-type ignorePattern struct {
-	pattern *regexp.Regexp
-	negate  bool
+// matches returns true if the pattern targets the path `f` itself, not
+// taking its parent directories into account.
+func (ip *ignorePattern) matches(f string, isDir bool) bool {
+	if ip.dirOnly && !isDir {
+		return false
+	}
+	if !ip.anchored {
+		// Without a slash, the pattern can only match the basename, as if
+		// it was anchored to its directory
+		f = f[strings.LastIndexByte(f, '/')+1:]
+	}
+	if ip.ignoreCase {
+		return wildmatch(ip.tokens, f, true, true)
+	}
+	switch ip.kind {
+	case kindBasename, kindPath:
+		return f == ip.literal
+	case kindSuffix:
+		return strings.HasSuffix(f, ip.literal)
+	}
+	if !strings.HasPrefix(f, ip.prefix) || !strings.HasSuffix(f, ip.suffix) {
+		return false
+	}
+	return wildmatch(ip.tokens, f, true, false)
 }
 
 // GitIgnore wraps a list of ignore pattern.
 type GitIgnore struct {
 	patterns []*ignorePattern
+	index    patternIndex
+
+	// parentExclusion is set by WithParentExclusion
+	parentExclusion bool
+	// ignoreCase is set by WithIgnoreCase
+	ignoreCase bool
+	// base is set by WithBase
+	base string
+	// skipMissingFiles is set by WithSkipMissingFiles
+	skipMissingFiles bool
+	// cache is set by WithMatchCache
+	cache *matchCache
+	// filterWorkers is set by WithFilterWorkers
+	filterWorkers int
+}
+
+// Option configures a GitIgnore object created by New, CompileIgnoreFile
+// or CompileIgnoreFileE.
+type Option func(*GitIgnore)
+
+// WithIgnoreCase makes patterns match regardless of case, including within
+// bracket expressions, like git does with core.ignoreCase set to true on
+// case-insensitive filesystems. Like git, only ASCII letters are folded, so
+// "ü.txt" does not match "Ü.TXT". It must be given before adding patterns.
+func WithIgnoreCase() Option {
+	return func(gi *GitIgnore) {
+		gi.ignoreCase = true
+	}
+}
+
+// WithParentExclusion makes matching follow git's directory traversal: the
+// parent directories of a path are evaluated first, and once one of them is
+// excluded the path is excluded as well, whatever patterns follow [Rule 4].
+// Without it, a negated pattern such as "!abc/b" re-includes "abc/b/b.js"
+// even though "abc" is excluded.
+func WithParentExclusion() Option {
+	return func(gi *GitIgnore) {
+		gi.parentExclusion = true
+	}
+}
+
+// WithBase sets the directory the patterns are relative to, which is the
+// directory of the ignore file [Rule 6]. Paths are still given relative to
+// the top of the work tree: with a base of "sub", "/foo" matches "sub/foo"
+// but not "foo", and paths outside of "sub" never match.
+func WithBase(base string) Option {
+	return func(gi *GitIgnore) {
+		gi.base = path.Clean(cleanPath(base))
+		if gi.base == "." {
+			gi.base = ""
+		}
+	}
+}
+
+// WithSkipMissingFiles makes the ignore files which do not exist behave
+// as empty files, as git does for an absent .gitignore file, instead of
+// failing. Other errors, such as a denied permission, are still reported.
+func WithSkipMissingFiles() Option {
+	return func(gi *GitIgnore) {
+		gi.skipMissingFiles = true
+	}
+}
+
+// New returns an empty GitIgnore object configured with the given options.
+// Patterns are added with AddPatternsFromLines or AddPatternsFromFiles.
+func New(opts ...Option) *GitIgnore {
+	gi := &GitIgnore{}
+	for _, opt := range opts {
+		opt(gi)
+	}
+	return gi
 }
 
 // CompileIgnoreLines accepts a variadic set of strings, and returns
 // a GitIgnore object which converts and appends the lines in the input
-// to regexp.Regexp patterns held within the GitIgnore objects "patterns" field
+// to patterns held within the GitIgnore objects "patterns" field
 func CompileIgnoreLines(lines ...string) *GitIgnore {
-	gi := &GitIgnore{}
-	for _, line := range lines {
-		pattern, negatePattern := getPatternFromLine(line)
-		if pattern != nil {
-			ip := &ignorePattern{pattern, negatePattern}
-			gi.patterns = append(gi.patterns, ip)
-		}
-	}
+	gi := New()
+	gi.addPatterns("", lines)
 	return gi
 }
 
+// CompileIgnoreLinesE is like CompileIgnoreLines, but also returns the
+// lines which are not valid patterns as ParseErrors. The returned
+// GitIgnore object holds the patterns which could be compiled.
+func CompileIgnoreLinesE(lines ...string) (*GitIgnore, error) {
+	gi := New()
+	if errs := gi.addPatterns("", lines); errs != nil {
+		return gi, errs
+	}
+	return gi, nil
+}
+
 // CompileIgnoreFile accepts a ignore file as the input, parses
-// the lines out of the file and invokes the CompileIgnoreLines method
-func CompileIgnoreFile(fpath string) (*GitIgnore, error) {
-	buffer, err := ioutil.ReadFile(fpath)
+// the lines out of the file and invokes the CompileIgnoreLines method.
+// The GitIgnore object is configured with the given options.
+func CompileIgnoreFile(fpath string, opts ...Option) (*GitIgnore, error) {
+	gi, _, err := compileIgnoreFile(nil, fpath, opts)
+	return gi, err
+}
+
+// CompileIgnoreFileE is like CompileIgnoreFile, but also returns the
+// lines which are not valid patterns as ParseErrors, along with the
+// GitIgnore object holding the patterns which could be compiled. If the
+// file cannot be read, the error is returned as is with a nil object.
+func CompileIgnoreFileE(fpath string, opts ...Option) (*GitIgnore, error) {
+	gi, errs, err := compileIgnoreFile(nil, fpath, opts)
 	if err != nil {
 		return nil, err
 	}
-	s := strings.Split(string(buffer), "\n")
-	return CompileIgnoreLines(s...), nil
+	if errs != nil {
+		return gi, errs
+	}
+	return gi, nil
+}
+
+// CompileIgnoreFS is like CompileIgnoreFile, but reads the ignore file
+// `name` from the file system `fsys`.
+func CompileIgnoreFS(fsys fs.FS, name string, opts ...Option) (*GitIgnore, error) {
+	gi, _, err := compileIgnoreFile(fsys, name, opts)
+	return gi, err
+}
+
+// CompileIgnoreReader accepts a reader of an ignore file, such as a
+// request body or the standard input, and parses its lines as they are
+// read. CRLF line endings and a UTF-8 byte order mark are handled. The
+// patterns come from `source`, as reported by Match. The GitIgnore object
+// is configured with the given options.
+func CompileIgnoreReader(r io.Reader, source string, opts ...Option) (*GitIgnore, error) {
+	gi := New(opts...)
+	if _, err := gi.addPatternsFromReader(r, source); err != nil {
+		return nil, err
+	}
+	return gi, nil
+}
+
+// CompileIgnoreReaderE is like CompileIgnoreReader, but also returns the
+// lines which are not valid patterns as ParseErrors, whose Source is
+// `source`, along with the GitIgnore object holding the patterns which
+// could be compiled. If `r` cannot be read, the error is returned as is
+// with a nil object.
+func CompileIgnoreReaderE(r io.Reader, source string, opts ...Option) (*GitIgnore, error) {
+	gi := New(opts...)
+	errs, err := gi.addPatternsFromReader(r, source)
+	if err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		return gi, errs
+	}
+	return gi, nil
+}
+
+// compileIgnoreFile reads the ignore file `name` from the file system
+// `fsys`, or from the OS one if `fsys` is nil. It returns the GitIgnore
+// object with the given options, along with the lines which are not valid
+// patterns, unless the file cannot be read.
+func compileIgnoreFile(fsys fs.FS, name string, opts []Option) (*GitIgnore, ParseErrors, error) {
+	gi := New(opts...)
+	errs, err := gi.addPatternsFromFile(fsys, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return gi, errs, nil
+}
+
+// addPatternsFromFile is like addPatternsFromReader, but reads the ignore
+// file `name` from the file system `fsys`, or from the OS one if `fsys` is
+// nil. The errors of reading the file are returned as *fs.PathError.
+func (gi *GitIgnore) addPatternsFromFile(fsys fs.FS, name string) (ParseErrors, error) {
+	var f io.ReadCloser
+	var err error
+	if fsys != nil {
+		f, err = fsys.Open(name)
+	} else {
+		f, err = os.Open(name)
+	}
+	if err != nil {
+		if gi.skipMissingFiles && errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	errs, err := gi.addPatternsFromReader(f, name)
+	var pathErr *fs.PathError
+	if err != nil && !errors.As(err, &pathErr) {
+		err = &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return errs, err
+}
+
+// addPatternsFromFiles reads the ignore files `names` like
+// addPatternsFromFile, going on after a failure, and returns every error
+// as FileErrors.
+func (gi *GitIgnore) addPatternsFromFiles(fsys fs.FS, names []string) error {
+	var errs FileErrors
+	for _, name := range names {
+		perrs, err := gi.addPatternsFromFile(fsys, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, perr := range perrs {
+			errs = append(errs, perr)
+		}
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
 }
 
 // CompileIgnoreFileAndLines accepts a ignore file as the input,
 // parses the lines out of the file and invokes the CompileIgnoreLines
 // method with additional lines
 func CompileIgnoreFileAndLines(fpath string, lines ...string) (*GitIgnore, error) {
-	buffer, err := ioutil.ReadFile(fpath)
+	gi, _, err := compileIgnoreFile(nil, fpath, nil)
 	if err != nil {
 		return nil, err
 	}
-	s := strings.Split(string(buffer), "\n")
-	return CompileIgnoreLines(append(s, lines...)...), nil
+	return gi.AddPatternsFromLines(lines...), nil
 }
 
 // MatchesPath returns true if the given GitIgnore structure would target
-// a given path string `f`.
+// a given path string `f`. A trailing slash marks `f` as a directory.
+// It does not allocate if `f` is slash-separated, unless WithMatchCache
+// is set and the directory of `f` is not cached yet.
 func (gi *GitIgnore) MatchesPath(f string) bool {
-	// Replace OS-specific path separator.
-	f = strings.Replace(f, string(os.PathSeparator), "/", -1)
-
-	matchesPath := false
-	for _, ip := range gi.patterns {
-		if ip.pattern.MatchString(f) {
-			// If this is a regular target (not negated with a gitignore exclude "!" etc)
-			if !ip.negate {
-				matchesPath = true
-			} else if matchesPath {
-				// Negated pattern, and matchesPath is already set
-				matchesPath = false
+	isDir := strings.HasSuffix(f, "/") || strings.HasSuffix(f, string(os.PathSeparator))
+	return gi.MatchesPathIsDir(f, isDir)
+}
+
+// MatchesBytes is like MatchesPath, for a path held in a buffer, such as a
+// line read from the output of `git ls-files`. It does not copy `f`, which
+// is not retained.
+func (gi *GitIgnore) MatchesBytes(f []byte) bool {
+	return gi.MatchesPath(bytesToString(f))
+}
+
+// MatchesPathIsDir returns true if the given GitIgnore structure would
+// target a given path string `f`, which is a directory if `isDir` is true.
+// Patterns ending with a slash only match directories [Rule 5].
+func (gi *GitIgnore) MatchesPathIsDir(f string, isDir bool) bool {
+	ip := gi.match(f, isDir, false)
+	return ip != nil && !ip.negate
+}
+
+// MatchResult describes the pattern which decided whether a path is
+// ignored, similar to the output of `git check-ignore -v`.
+type MatchResult struct {
+	// Pattern is the pattern as written in the source, including a
+	// leading "!" for negated patterns.
+	Pattern string
+	// Source is the file the pattern was read from. It is empty for
+	// patterns compiled from lines.
+	Source string
+	// Line is the 1-based line number of the pattern within its source.
+	Line int
+	// Negate is true if the pattern starts with "!" and therefore
+	// re-includes the path.
+	Negate bool
+}
+
+// Ignored returns true if the path is ignored, that is, if a pattern
+// matched and it is not negated.
+func (mr *MatchResult) Ignored() bool {
+	return mr != nil && !mr.Negate
+}
+
+// Match returns the pattern which decides whether the given path string
+// `f` is ignored, or nil if no pattern targets it. A trailing slash marks
+// `f` as a directory.
+func (gi *GitIgnore) Match(f string) *MatchResult {
+	isDir := strings.HasSuffix(f, "/") || strings.HasSuffix(f, string(os.PathSeparator))
+	return gi.MatchIsDir(f, isDir)
+}
+
+// MatchIsDir is like Match, but the caller states whether the path `f`
+// is a directory.
+func (gi *GitIgnore) MatchIsDir(f string, isDir bool) *MatchResult {
+	return newMatchResult(gi.match(f, isDir, true))
+}
+
+// newMatchResult describes the pattern `ip`, which may be nil.
+func newMatchResult(ip *ignorePattern) *MatchResult {
+	if ip == nil {
+		return nil
+	}
+	return &MatchResult{
+		Pattern: ip.text,
+		Source:  ip.source,
+		Line:    ip.lineNo,
+		Negate:  ip.negate,
+	}
+}
+
+// match returns the pattern which decides whether the path `f` is
+// ignored, or nil if no pattern targets it. Unless `exact` is true, any
+// pattern targeting the path may be returned if none of the patterns is
+// negated, since they all ignore it.
+func (gi *GitIgnore) match(f string, isDir bool, exact bool) *ignorePattern {
+	f, ok := gi.relPath(f)
+	if !ok {
+		return nil
+	}
+
+	if gi.cache != nil {
+		if i := gi.cachedMatch(f, isDir, exact); i >= 0 {
+			return gi.patterns[i]
+		}
+		return nil
+	}
+
+	if gi.parentExclusion {
+		// Like git, stop at the first excluded parent directory
+		for i := 0; i < len(f); i++ {
+			if f[i] != '/' {
+				continue
 			}
+			if ip := gi.lastMatch(f[:i], true); ip != nil && !ip.negate {
+				return ip
+			}
+		}
+		return gi.lastMatch(f, isDir)
+	}
+
+	// The last pattern targeting the path or one of its parents wins. The
+	// patterns are tried from the last one, so stop once it is found.
+	stop := len(gi.patterns) - 1
+	if !exact && !gi.index.negated {
+		stop = 0
+	}
+	best := gi.index.last(gi.patterns, f, isDir, gi.ignoreCase, -1)
+	for i := len(f) - 1; i > 0 && best < stop; i-- {
+		if f[i] == '/' {
+			best = gi.index.last(gi.patterns, f[:i], true, gi.ignoreCase, best)
 		}
 	}
-	return matchesPath
+	if best < 0 {
+		return nil
+	}
+	return gi.patterns[best]
+}
+
+// decide returns the index of the pattern deciding whether the path `f` is
+// ignored, or -1, given the decision `parent` made for its parent
+// directory.
+func (gi *GitIgnore) decide(f string, isDir bool, parent int) int {
+	if gi.parentExclusion {
+		if parent >= 0 && !gi.patterns[parent].negate {
+			return parent
+		}
+		return gi.index.last(gi.patterns, f, isDir, gi.ignoreCase, -1)
+	}
+	if parent == len(gi.patterns)-1 {
+		return parent
+	}
+	return gi.index.last(gi.patterns, f, isDir, gi.ignoreCase, parent)
+}
+
+// lastMatch returns the last pattern targeting the path `f` itself, not
+// taking its parent directories into account.
+func (gi *GitIgnore) lastMatch(f string, isDir bool) *ignorePattern {
+	if i := gi.index.last(gi.patterns, f, isDir, gi.ignoreCase, -1); i >= 0 {
+		return gi.patterns[i]
+	}
+	return nil
+}
+
+// MatchesDirEntry returns true if the given GitIgnore structure would
+// target the entry `d` found at path `f`, e.g. while walking a directory.
+func (gi *GitIgnore) MatchesDirEntry(f string, d fs.DirEntry) bool {
+	return gi.MatchesPathIsDir(f, d.IsDir())
+}
+
+// MatchesFileInfo returns true if the given GitIgnore structure would
+// target the file described by `fi` found at path `f`.
+func (gi *GitIgnore) MatchesFileInfo(f string, fi os.FileInfo) bool {
+	return gi.MatchesPathIsDir(f, fi.IsDir())
+}
+
+// Base returns the directory the patterns are relative to, as set by
+// WithBase. It is empty for the top of the work tree.
+func (gi *GitIgnore) Base() string {
+	return gi.base
+}
+
+// relPath returns the path `f` cleaned and relative to the base directory,
+// and false if it cannot be matched, being outside of the base directory
+// or empty.
+func (gi *GitIgnore) relPath(f string) (string, bool) {
+	f = cleanPath(f)
+	if gi.base != "" {
+		var ok bool
+		if f, ok = gi.trimBase(f); !ok {
+			return "", false
+		}
+	}
+	return f, f != ""
+}
+
+// trimBase returns the path `f` relative to the base directory, and false
+// if `f` is not inside of it.
+func (gi *GitIgnore) trimBase(f string) (string, bool) {
+	if len(f) <= len(gi.base) || f[len(gi.base)] != '/' {
+		return "", false
+	}
+	prefix := f[:len(gi.base)]
+	if prefix != gi.base && !(gi.ignoreCase && equalFoldASCII(prefix, gi.base)) {
+		return "", false
+	}
+	return f[len(gi.base)+1:], true
+}
+
+// cleanPath converts the OS-specific path separator to a slash and strips
+// leading and trailing slashes, so `f` can be matched as a relative path.
+// It only allocates if `f` holds another separator than a slash.
+func cleanPath(f string) string {
+	if os.PathSeparator != '/' {
+		f = strings.Replace(f, string(os.PathSeparator), "/", -1)
+	}
+	return strings.Trim(f, "/")
 }
 
 // AddPatternsFromFiles appends the patterns returned from CompileIgnoreLines
 // to the current GitIgnore object.
-// It returns the object, which means it can be chained
+// It returns the object, which means it can be chained.
+// It stops silently at the first file which cannot be read, unless it is
+// missing and WithSkipMissingFiles is set: use AddPatternsFromFilesE to
+// get the errors
 func (gi *GitIgnore) AddPatternsFromFiles(fpaths ...string) *GitIgnore {
 	for _, fpath := range fpaths {
-		i, err := CompileIgnoreFile(fpath)
-		if err != nil {
+		if _, err := gi.addPatternsFromFile(nil, fpath); err != nil {
+			return gi
+		}
+	}
+
+	return gi
+}
+
+// AddPatternsFromFilesE is like AddPatternsFromFiles, but reads every
+// file, even after a failure, and returns the files which cannot be read
+// and the lines which are not valid patterns as FileErrors. Missing files
+// are only skipped with WithSkipMissingFiles.
+func (gi *GitIgnore) AddPatternsFromFilesE(fpaths ...string) error {
+	return gi.addPatternsFromFiles(nil, fpaths)
+}
+
+// AddPatternsFromFS is like AddPatternsFromFiles, but reads the ignore
+// files `names` from the file system `fsys`.
+// It returns the object, which means it can be chained
+func (gi *GitIgnore) AddPatternsFromFS(fsys fs.FS, names ...string) *GitIgnore {
+	for _, name := range names {
+		if _, err := gi.addPatternsFromFile(fsys, name); err != nil {
 			return gi
 		}
-		gi.patterns = append(gi.patterns, i.patterns...)
 	}
 
 	return gi
 }
 
+// AddPatternsFromFSE is like AddPatternsFromFilesE, but reads the ignore
+// files `names` from the file system `fsys`.
+func (gi *GitIgnore) AddPatternsFromFSE(fsys fs.FS, names ...string) error {
+	return gi.addPatternsFromFiles(fsys, names)
+}
+
 // AddPatternsFromLines appends the patterns returned from CompileIgnoreLines
 // to the current GitIgnore object.
 // It returns the object, which means it can be chained
 func (gi *GitIgnore) AddPatternsFromLines(lines ...string) *GitIgnore {
-	patterns := CompileIgnoreLines(lines...).patterns
-	gi.patterns = append(gi.patterns, patterns...)
+	gi.addPatterns("", lines)
 	return gi
 }
+
+// AddPatternsFromLinesE is like AddPatternsFromLines, but also returns the
+// lines which are not valid patterns as ParseErrors, like
+// CompileIgnoreLinesE. It is meant for a GitIgnore object configured with
+// options, e.g. New(WithIgnoreCase()).
+func (gi *GitIgnore) AddPatternsFromLinesE(lines ...string) error {
+	if errs := gi.addPatterns("", lines); errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// bytesToString returns the bytes `b` as a string without copying them. The
+// string must not be retained, nor `b` modified while it is used.
+func bytesToString(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
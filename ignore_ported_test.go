@@ -107,7 +107,8 @@ func TestDoubleStar(test *testing.T) {
 	lines := []string{"foo/**/"}
 	object := CompileIgnoreLines(lines...)
 
-	shouldMatch(test, object, "foo/")
+	// Like git, a trailing "/**" only matches inside of the directory
+	shouldNotMatch(test, object, "foo/")
 	shouldMatch(test, object, "foo/abc/")
 	shouldMatch(test, object, "foo/x/y/z/")
 	shouldNotMatch(test, object, "foo")
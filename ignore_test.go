@@ -2,13 +2,17 @@
 package ignore
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 
 	"io/ioutil"
 	"path/filepath"
 
 	"fmt"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"runtime"
 
@@ -406,3 +410,647 @@ func BenchmarkCompileIgnoreLines(b *testing.B) {
 		assert.Equal(b, false, object.MatchesPath("bd"), "bd should not match")
 	}
 }
+
+// templateLines returns 500 lines shaped like the ones of real .gitignore
+// templates: names, extensions, anchored paths and a few globs.
+func templateLines() []string {
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprintf("name%d", i))
+	}
+	for i := 0; i < 150; i++ {
+		lines = append(lines, fmt.Sprintf("*.ext%d", i))
+	}
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("/dir%d/", i), fmt.Sprintf("!/dir%d/keep%d", i, i))
+	}
+	for i := 0; i < 25; i++ {
+		lines = append(lines, fmt.Sprintf("**/tmp%d/*.bak", i), fmt.Sprintf("file?%d[0-9]", i))
+	}
+	return lines
+}
+
+// templatePaths are matched against templateLines by the benchmarks
+var templatePaths = []string{
+	"src/github.com/user/project/internal/pkg/file.go",
+	"src/github.com/user/project/name150",
+	"src/github.com/user/project/debug.ext99",
+	"dir42/sub/file.txt",
+	"a/b/tmp7/x.bak",
+}
+
+func benchmarkMatchesPath(b *testing.B, opts ...Option) {
+	object := New(opts...).AddPatternsFromLines(templateLines()...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, f := range templatePaths {
+			object.MatchesPath(f)
+		}
+	}
+}
+
+func BenchmarkMatchesPathTemplate(b *testing.B) {
+	benchmarkMatchesPath(b)
+}
+
+func BenchmarkMatchesPathTemplateIgnoreCase(b *testing.B) {
+	benchmarkMatchesPath(b, WithIgnoreCase())
+}
+
+func BenchmarkMatchesPathTemplateParentExclusion(b *testing.B) {
+	benchmarkMatchesPath(b, WithParentExclusion())
+}
+
+// globLines returns `n` lines which are all globs, none of them negated
+func globLines(n int) []string {
+	var lines []string
+	for i := 0; i < n; i++ {
+		switch i % 4 {
+		case 0:
+			lines = append(lines, fmt.Sprintf("**/cache%d/*.bin", i))
+		case 1:
+			lines = append(lines, fmt.Sprintf("/out%d*", i))
+		case 2:
+			lines = append(lines, fmt.Sprintf("*.py[cod]%d", i))
+		default:
+			lines = append(lines, fmt.Sprintf("tmp-%d-*.txt", i))
+		}
+	}
+	return lines
+}
+
+// Most paths of a work tree are not ignored
+var unmatchedPaths = []string{
+	"src/github.com/user/project/internal/pkg/file.go",
+	"src/github.com/user/project/README.md",
+	"docs/index.html",
+	"Makefile",
+}
+
+func benchmarkMatchesPathLines(b *testing.B, lines []string, paths []string) {
+	object := CompileIgnoreLines(lines...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, f := range paths {
+			object.MatchesPath(f)
+		}
+	}
+}
+
+func BenchmarkMatchesPathGlobsNoMatch(b *testing.B) {
+	benchmarkMatchesPathLines(b, globLines(1000), unmatchedPaths)
+}
+
+func BenchmarkMatchesPathGlobsMatch(b *testing.B) {
+	benchmarkMatchesPathLines(b, globLines(1000), []string{"a/b/cache0/x.bin", "out1/x/y/z", "lib/x.pyc2"})
+}
+
+func BenchmarkMatchesPathTemplateNoMatch(b *testing.B) {
+	benchmarkMatchesPathLines(b, templateLines(), unmatchedPaths)
+}
+
+// Validate the correct handling of directory-only patterns [Rule 5]
+func TestMatchesPathIsDir(test *testing.T) {
+	object := CompileIgnoreLines("foo/", "bar", "baz/**/")
+
+	assert.True(test, object.MatchesPathIsDir("foo", true), "foo directory should match")
+	assert.False(test, object.MatchesPathIsDir("foo", false), "foo file should not match")
+	assert.True(test, object.MatchesPathIsDir("foo/a", false), "foo/a file should match")
+	assert.True(test, object.MatchesPathIsDir("a/foo", true), "a/foo directory should match")
+	assert.False(test, object.MatchesPathIsDir("a/foo", false), "a/foo file should not match")
+
+	assert.True(test, object.MatchesPathIsDir("bar", true), "bar directory should match")
+	assert.True(test, object.MatchesPathIsDir("bar", false), "bar file should match")
+
+	assert.True(test, object.MatchesPathIsDir("baz/x", true), "baz/x directory should match")
+	assert.True(test, object.MatchesPathIsDir("baz/x/y", false), "baz/x/y file should match")
+	assert.False(test, object.MatchesPathIsDir("baz", false), "baz file should not match")
+
+	// Like git, only one trailing slash is stripped
+	object = CompileIgnoreLines("foo//")
+	assert.False(test, object.MatchesPathIsDir("foo", true), "foo directory should not match")
+	assert.False(test, object.MatchesPath("foo/a"), "foo/a should not match")
+	assert.False(test, object.MatchesPath("x/foo/b"), "x/foo/b should not match")
+}
+
+func TestMatchesPathAllocs(test *testing.T) {
+	if raceEnabled {
+		test.Skip("the race detector makes matching allocate")
+	}
+	paths := []string{
+		"node_modules/lib/index.js",
+		"src/Foo/Bar.LOG",
+		"src/ÄÖ/x.go",
+		"/src/a/b/c/d/e/f.txt/",
+		strings.Repeat("Deep/", 50) + "File.GO",
+	}
+	for _, opts := range [][]Option{
+		nil,
+		{WithParentExclusion()},
+		{WithIgnoreCase()},
+		{WithBase("src")},
+	} {
+		object := New(opts...).AddPatternsFromLines(templateLines()...)
+		for _, f := range paths {
+			allocs := testing.AllocsPerRun(100, func() {
+				object.MatchesPath(f)
+			})
+			assert.Zero(test, allocs, f)
+
+			b := []byte(f)
+			allocs = testing.AllocsPerRun(100, func() {
+				object.MatchesBytes(b)
+			})
+			assert.Zero(test, allocs, f)
+		}
+	}
+}
+
+func TestMatchesBytes(test *testing.T) {
+	object := CompileIgnoreLines("build/", "*.log", "!keep.log")
+
+	assert.True(test, object.MatchesBytes([]byte("build/a.go")))
+	assert.True(test, object.MatchesBytes([]byte("build/")))
+	assert.False(test, object.MatchesBytes([]byte("build")))
+	assert.True(test, object.MatchesBytes([]byte("a/debug.log")))
+	assert.False(test, object.MatchesBytes([]byte("a/keep.log")))
+	assert.False(test, object.MatchesBytes(nil))
+}
+
+func TestMatchesDirEntry(test *testing.T) {
+	dir := test.TempDir()
+	assert.NoError(test, os.Mkdir(filepath.Join(dir, "foo"), os.ModePerm))
+	assert.NoError(test, ioutil.WriteFile(filepath.Join(dir, "bar"), nil, os.ModePerm))
+
+	object := CompileIgnoreLines("foo/", "bar/")
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(test, err)
+	assert.Len(test, entries, 2)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		assert.NoError(test, err)
+
+		expected := entry.Name() == "foo"
+		assert.Equal(test, expected, object.MatchesDirEntry(entry.Name(), entry), entry.Name())
+		assert.Equal(test, expected, object.MatchesFileInfo(entry.Name(), info), entry.Name())
+	}
+}
+
+// Validate that a file cannot be re-included if a parent directory is excluded
+func TestWithParentExclusion(test *testing.T) {
+	lines := []string{"abc", "!abc/b"}
+
+	object := CompileIgnoreLines(lines...)
+	assert.False(test, object.MatchesPath("abc/b/b.js"), "abc/b/b.js should be re-included by default")
+
+	object = New(WithParentExclusion()).AddPatternsFromLines(lines...)
+	assert.True(test, object.MatchesPath("abc/a.js"), "abc/a.js should match")
+	assert.True(test, object.MatchesPath("abc/b/b.js"), "abc/b/b.js should match, abc is excluded")
+	assert.True(test, object.MatchesPath("abc/b/"), "abc/b/ should match, abc is excluded")
+
+	object = New(WithParentExclusion()).AddPatternsFromLines("/*", "!/foo", "/foo/*", "!/foo/bar")
+	assert.True(test, object.MatchesPath("a/b"), "a/b should match")
+	assert.True(test, object.MatchesPath("foo/baz/a"), "foo/baz/a should match")
+	assert.False(test, object.MatchesPath("foo/bar/a"), "foo/bar/a should not match")
+	assert.False(test, object.MatchesPathIsDir("foo", true), "foo should not match")
+
+	// A trailing "/**" does not exclude the directory itself
+	object = New(WithParentExclusion()).AddPatternsFromLines("abc/**", "!abc/keep")
+	assert.False(test, object.MatchesPath("abc/keep"), "abc/keep should not match")
+	assert.True(test, object.MatchesPath("abc/other"), "abc/other should match")
+}
+
+// Validate "Match()" reports the pattern which decided the outcome
+func TestMatch(test *testing.T) {
+	filename := writeFileToTestDir(test, "test.gitignore", `
+# Comment
+*.log
+!important.log
+build/
+`)
+
+	object, err := CompileIgnoreFileAndLines(filename, "tmp")
+	assert.NoError(test, err)
+
+	result := object.Match("debug.log")
+	if assert.NotNil(test, result, "debug.log should match") {
+		assert.Equal(test, "*.log", result.Pattern)
+		assert.Equal(test, filename, result.Source)
+		assert.Equal(test, 3, result.Line)
+		assert.False(test, result.Negate)
+		assert.True(test, result.Ignored(), "debug.log should be ignored")
+	}
+
+	result = object.Match("a/important.log")
+	if assert.NotNil(test, result, "a/important.log should match") {
+		assert.Equal(test, "!important.log", result.Pattern)
+		assert.Equal(test, 4, result.Line)
+		assert.True(test, result.Negate)
+		assert.False(test, result.Ignored(), "a/important.log should not be ignored")
+	}
+
+	result = object.MatchIsDir("build", true)
+	if assert.NotNil(test, result, "build should match") {
+		assert.Equal(test, "build/", result.Pattern)
+		assert.Equal(test, 5, result.Line)
+	}
+
+	result = object.Match("tmp/a")
+	if assert.NotNil(test, result, "tmp/a should match") {
+		assert.Equal(test, "tmp", result.Pattern)
+		assert.Equal(test, "", result.Source)
+		assert.Equal(test, 1, result.Line)
+	}
+
+	assert.Nil(test, object.MatchIsDir("build", false), "build file should not match")
+	assert.Nil(test, object.Match("main.go"), "main.go should not match")
+	assert.False(test, object.Match("main.go").Ignored(), "main.go should not be ignored")
+}
+
+// Validate the errors reported by "CompileIgnoreLinesE()"
+func TestCompileIgnoreLinesE(test *testing.T) {
+	object, err := CompileIgnoreLinesE("*.log", "", "# comment", "foo**bar", "  !a/[bc", "b/**/c")
+	assert.NotNil(test, object, "object should not be nil")
+
+	var errs ParseErrors
+	if assert.True(test, errors.As(err, &errs), "error should be ParseErrors") && assert.Len(test, errs, 2) {
+		assert.Equal(test, &ParseError{Line: 4, Column: 4, Reason: `invalid "**", it must be a whole path component`}, errs[0])
+		assert.Equal(test, &ParseError{Line: 5, Column: 6, Reason: "unterminated bracket expression"}, errs[1])
+		assert.Equal(test, "<lines>:5:6: unterminated bracket expression", errs[1].Error())
+	}
+
+	// Valid patterns are kept
+	assert.True(test, object.MatchesPath("debug.log"), "debug.log should match")
+	assert.True(test, object.MatchesPath("b/x/c"), "b/x/c should match")
+	assert.True(test, object.MatchesPath("fooxbar"), "fooxbar should match")
+
+	object, err = CompileIgnoreLinesE("*.log", "**/foo", "foo/**", "a/**/b")
+	assert.NoError(test, err)
+	assert.Equal(test, 4, len(object.patterns))
+}
+
+func TestAddPatternsFromLinesE(test *testing.T) {
+	object := New(WithIgnoreCase())
+	err := object.AddPatternsFromLinesE("*.LOG", "[z-", "Build/")
+
+	var errs ParseErrors
+	if assert.True(test, errors.As(err, &errs), "error should be ParseErrors") && assert.Len(test, errs, 1) {
+		assert.Equal(test, &ParseError{Line: 2, Column: 1, Reason: "unterminated bracket expression"}, errs[0])
+	}
+	assert.True(test, object.MatchesPath("debug.log"), "debug.log should match")
+	assert.True(test, object.MatchesPath("build/"), "build/ should match")
+
+	assert.NoError(test, object.AddPatternsFromLinesE("*.tmp"))
+	assert.True(test, object.MatchesPath("A.TMP"), "A.TMP should match")
+}
+
+func TestCompileIgnoreFileE(test *testing.T) {
+	filename := writeFileToTestDir(test, "test.gitignore", `
+abc
+[abc
+`)
+
+	object, err := CompileIgnoreFileE(filename)
+	assert.NotNil(test, object, "object should not be nil")
+	assert.True(test, object.MatchesPath("abc"), "abc should match")
+
+	var errs ParseErrors
+	if assert.True(test, errors.As(err, &errs), "error should be ParseErrors") && assert.Len(test, errs, 1) {
+		assert.Equal(test, &ParseError{Source: filename, Line: 3, Column: 1, Reason: "unterminated bracket expression"}, errs[0])
+	}
+
+	object, err = CompileIgnoreFileE("doesntexist")
+	assert.Nil(test, object, "object should be nil")
+	assert.True(test, os.IsNotExist(err), "error should be unknown file")
+}
+
+// Validate the correct handling of the "?" wildcard
+func TestQuestionMark(test *testing.T) {
+	object := CompileIgnoreLines("file?.log", "??.tmp", `what\?`)
+
+	assert.True(test, object.MatchesPath("file1.log"), "file1.log should match")
+	assert.True(test, object.MatchesPath("a/fileX.log"), "a/fileX.log should match")
+	assert.False(test, object.MatchesPath("file.log"), "file.log should not match")
+	assert.False(test, object.MatchesPath("file12.log"), "file12.log should not match")
+	assert.False(test, object.MatchesPath("file/.log"), "file/.log should not match")
+
+	assert.True(test, object.MatchesPath("ab.tmp"), "ab.tmp should match")
+	assert.False(test, object.MatchesPath("a.tmp"), "a.tmp should not match")
+	assert.False(test, object.MatchesPath("abc.tmp"), "abc.tmp should not match")
+
+	assert.True(test, object.MatchesPath("what?"), "what? should match")
+	assert.False(test, object.MatchesPath("whatx"), "whatx should not match")
+	assert.False(test, object.MatchesPath("wha"), "wha should not match")
+}
+
+// Validate the correct handling of leading and trailing spaces [Rule 3]
+func TestCompileIgnoreLinesTrailingSpaces(test *testing.T) {
+	object := CompileIgnoreLines("trailing   ", `escaped\ `, `escaped2\  `, `middle\ \ space`, "  leading", `odd\\ `)
+
+	assert.True(test, object.MatchesPath("trailing"), "trailing should match")
+	assert.False(test, object.MatchesPath("trailing "), "'trailing ' should not match")
+
+	assert.True(test, object.MatchesPath("escaped "), "'escaped ' should match")
+	assert.False(test, object.MatchesPath("escaped"), "escaped should not match")
+	assert.True(test, object.MatchesPath("escaped2 "), "'escaped2 ' should match")
+	assert.False(test, object.MatchesPath("escaped2  "), "'escaped2  ' should not match")
+	assert.True(test, object.MatchesPath("middle  space"), "'middle  space' should match")
+
+	assert.True(test, object.MatchesPath("  leading"), "'  leading' should match")
+	assert.False(test, object.MatchesPath("leading"), "leading should not match")
+
+	result := object.Match("trailing")
+	if assert.NotNil(test, result) {
+		assert.Equal(test, "trailing", result.Pattern)
+	}
+
+	assert.Equal(test, "a", trimTrailingSpaces("a  "))
+	assert.Equal(test, `a\ `, trimTrailingSpaces(`a\  `))
+	assert.Equal(test, `a\\`, trimTrailingSpaces(`a\\ `))
+	assert.Equal(test, `a\`, trimTrailingSpaces(`a\`))
+	assert.Equal(test, "  a", trimTrailingSpaces("  a"))
+	assert.Equal(test, "", trimTrailingSpaces("   "))
+}
+
+// Validate that any character escaped with a \ is a literal
+func TestEscapedCharacters(test *testing.T) {
+	object := CompileIgnoreLines(`\[abc]`, `\a\b\c`, `back\\slash`, `star\*`, `\!bang`, `\#hash`, "a+b", "(x)", "x|y", "$HOME", "{a,b}", "^c")
+
+	assert.True(test, object.MatchesPath("[abc]"), "[abc] should match")
+	assert.False(test, object.MatchesPath("a"), "a should not match")
+	assert.True(test, object.MatchesPath("abc"), "abc should match")
+	assert.True(test, object.MatchesPath(`back\slash`), `back\slash should match`)
+	assert.True(test, object.MatchesPath("star*"), "star* should match")
+	assert.False(test, object.MatchesPath("starx"), "starx should not match")
+	assert.True(test, object.MatchesPath("!bang"), "!bang should match")
+	assert.True(test, object.MatchesPath("#hash"), "#hash should match")
+
+	// Regexp metacharacters are not special
+	assert.True(test, object.MatchesPath("a+b"), "a+b should match")
+	assert.False(test, object.MatchesPath("aab"), "aab should not match")
+	assert.True(test, object.MatchesPath("(x)"), "(x) should match")
+	assert.False(test, object.MatchesPath("x"), "x should not match")
+	assert.True(test, object.MatchesPath("x|y"), "x|y should match")
+	assert.True(test, object.MatchesPath("$HOME"), "$HOME should match")
+	assert.True(test, object.MatchesPath("{a,b}"), "{a,b} should match")
+	assert.True(test, object.MatchesPath("^c"), "^c should match")
+
+	_, err := CompileIgnoreLinesE(`trailing\`)
+	assert.EqualError(test, err, "<lines>:1:9: trailing backslash")
+}
+
+// Validate that a / in the middle of a pattern anchors it [Rule 6, 7]
+func TestMiddleSlashIsAnchored(test *testing.T) {
+	object := CompileIgnoreLines("abc/def", "**/ghi/jkl", "mno/**/pqr", "stu/**")
+
+	assert.True(test, object.MatchesPath("abc/def"), "abc/def should match")
+	assert.False(test, object.MatchesPath("x/abc/def"), "x/abc/def should not match")
+	assert.True(test, object.MatchesPath("x/ghi/jkl"), "x/ghi/jkl should match")
+	assert.True(test, object.MatchesPath("mno/pqr"), "mno/pqr should match")
+	assert.True(test, object.MatchesPath("mno/x/y/pqr"), "mno/x/y/pqr should match")
+	assert.False(test, object.MatchesPath("x/mno/pqr"), "x/mno/pqr should not match")
+	assert.True(test, object.MatchesPath("stu/x/y"), "stu/x/y should match")
+	assert.False(test, object.MatchesPath("stuff"), "stuff should not match")
+}
+
+// Validate the case-insensitive matching mode
+func TestWithIgnoreCase(test *testing.T) {
+	object := New(WithIgnoreCase()).AddPatternsFromLines("Build/", "*.LOG", "!keep.log")
+
+	assert.True(test, object.MatchesPath("build/"), "build/ should match")
+	assert.True(test, object.MatchesPath("BUILD/output"), "BUILD/output should match")
+	assert.True(test, object.MatchesPath("debug.log"), "debug.log should match")
+	assert.False(test, object.MatchesPath("KEEP.LOG"), "KEEP.LOG should not match")
+
+	object = CompileIgnoreLines("Build/")
+	assert.False(test, object.MatchesPath("build/"), "build/ should not match by default")
+
+	filename := writeFileToTestDir(test, "test.gitignore", `
+Build/
+`)
+	object, err := CompileIgnoreFile(filename, WithIgnoreCase())
+	assert.NoError(test, err)
+	assert.True(test, object.MatchesPath("build/"), "build/ should match")
+
+	object, err = CompileIgnoreFileE(filename, WithIgnoreCase())
+	assert.NoError(test, err)
+	assert.True(test, object.MatchesPath("bUiLd/"), "bUiLd/ should match")
+}
+
+// Validate that patterns are relative to the base directory [Rule 6]
+func TestWithBase(test *testing.T) {
+	dir := test.TempDir()
+	assert.NoError(test, os.Mkdir(filepath.Join(dir, "sub"), os.ModePerm))
+	filename := filepath.Join(dir, "sub", ".gitignore")
+	assert.NoError(test, ioutil.WriteFile(filename, []byte("/foo\nbar\n!/bar/baz\n"), os.ModePerm))
+
+	object, err := CompileIgnoreFile(filename, WithBase("sub/"))
+	assert.NoError(test, err)
+	assert.Equal(test, "sub", object.Base())
+
+	assert.True(test, object.MatchesPath("sub/foo"), "sub/foo should match")
+	assert.True(test, object.MatchesPath("sub/foo/a"), "sub/foo/a should match")
+	assert.False(test, object.MatchesPath("sub/x/foo"), "sub/x/foo should not match")
+	assert.False(test, object.MatchesPath("foo"), "foo should not match")
+	assert.True(test, object.MatchesPath("sub/x/bar"), "sub/x/bar should match")
+	assert.False(test, object.MatchesPath("sub/bar/baz"), "sub/bar/baz should not match")
+	assert.False(test, object.MatchesPath("bar"), "bar is outside of sub")
+	assert.False(test, object.MatchesPath("subway/bar"), "subway/bar is outside of sub")
+	assert.False(test, object.MatchesPathIsDir("sub", true), "sub should not match")
+
+	object = New(WithBase("a/b"), WithIgnoreCase()).AddPatternsFromLines("c")
+	assert.True(test, object.MatchesPath("A/B/C"), "A/B/C should match")
+	assert.False(test, object.MatchesPath("a/c"), "a/c should not match")
+
+	assert.Equal(test, "", New(WithBase(".")).Base())
+	assert.Equal(test, "a/b", New(WithBase("./a//b/")).Base())
+}
+
+func TestCompileIgnoreFS(test *testing.T) {
+	fsys := newMapFSForTest(map[string]string{
+		"sub/.gitignore": "*.log\n!keep.log\n",
+		"other":          "*.tmp\n",
+	})
+
+	object, err := CompileIgnoreFS(fsys, "sub/.gitignore", WithIgnoreCase())
+	assert.NoError(test, err)
+	assert.True(test, object.MatchesPath("debug.LOG"), "debug.LOG should match")
+	assert.False(test, object.MatchesPath("keep.log"), "keep.log should not match")
+
+	result := object.Match("debug.log")
+	if assert.NotNil(test, result) {
+		assert.Equal(test, "sub/.gitignore", result.Source)
+		assert.Equal(test, 1, result.Line)
+	}
+
+	object.AddPatternsFromFS(fsys, "other", "doesntexist")
+	assert.True(test, object.MatchesPath("x.tmp"), "x.tmp should match")
+
+	object, err = CompileIgnoreFS(fsys, "doesntexist")
+	assert.Nil(test, object, "object should be nil")
+	assert.True(test, errors.Is(err, fs.ErrNotExist), "error should be unknown file")
+}
+
+func TestCompileIgnoreReader(test *testing.T) {
+	object, err := CompileIgnoreReader(strings.NewReader("\ufeff*.log\r\n# comment\r\n!keep.log\r\n\r\nbuild/\r\n"), "<stdin>")
+	assert.NoError(test, err)
+	assert.True(test, object.MatchesPath("debug.log"), "debug.log should match")
+	assert.False(test, object.MatchesPath("keep.log"), "keep.log should not match")
+	assert.True(test, object.MatchesPath("build/"), "build/ should match")
+	assert.False(test, object.MatchesPath("build\r/"), "build\\r/ should not match")
+
+	result := object.Match("build/")
+	if assert.NotNil(test, result) {
+		assert.Equal(test, "build/", result.Pattern)
+		assert.Equal(test, "<stdin>", result.Source)
+		assert.Equal(test, 5, result.Line)
+	}
+
+	// The byte order mark is only skipped at the start of the file
+	object, err = CompileIgnoreReader(strings.NewReader("a\n\ufeffb"), "", WithIgnoreCase())
+	assert.NoError(test, err)
+	assert.True(test, object.MatchesPath("A"), "A should match")
+	assert.False(test, object.MatchesPath("b"), "b should not match")
+	assert.True(test, object.MatchesPath("\ufeffb"), "\\ufeffb should match")
+
+	object, err = CompileIgnoreReader(iotest.ErrReader(errors.New("read error")), "")
+	assert.Nil(test, object, "object should be nil")
+	assert.EqualError(test, err, "read error")
+}
+
+func TestCompileIgnoreReaderE(test *testing.T) {
+	object, err := CompileIgnoreReaderE(strings.NewReader("*.log\nfoo\\\n[z-\n"), "<stdin>", WithIgnoreCase())
+	if assert.IsType(test, ParseErrors{}, err) {
+		errs := err.(ParseErrors)
+		if assert.Len(test, errs, 2) {
+			assert.Equal(test, "<stdin>", errs[0].Source)
+			assert.Equal(test, 2, errs[0].Line)
+			assert.Equal(test, 3, errs[1].Line)
+		}
+	}
+	if assert.NotNil(test, object) {
+		assert.True(test, object.MatchesPath("DEBUG.LOG"), "DEBUG.LOG should match")
+	}
+
+	object, err = CompileIgnoreReaderE(strings.NewReader("*.log\n"), "")
+	assert.NoError(test, err)
+	assert.True(test, object.MatchesPath("debug.log"), "debug.log should match")
+
+	object, err = CompileIgnoreReaderE(iotest.ErrReader(errors.New("read error")), "")
+	assert.Nil(test, object, "object should be nil")
+	assert.EqualError(test, err, "read error")
+}
+
+// Validate that lines are not limited in length, as they were read whole
+// before being streamed
+func TestCompileIgnoreFileLongLine(test *testing.T) {
+	long := strings.Repeat("a", 70000)
+	filename := writeFileToTestDir(test, "test.gitignore", "*.log\n"+long+"\nbuild/")
+
+	object, err := CompileIgnoreFile(filename)
+	assert.NoError(test, err)
+	assert.True(test, object.MatchesPath(long), "the long line should match")
+	assert.True(test, object.MatchesPath("build/"), "build/ should match")
+
+	result := object.Match("build/")
+	if assert.NotNil(test, result) {
+		assert.Equal(test, 3, result.Line)
+	}
+
+	isolateHomeForTest(test)
+	root := writeTreeToTestDir(test, map[string]string{
+		".gitignore": long + "\n*.log\n",
+		"debug.log":  "",
+	})
+	r, err := NewRepository(root)
+	assert.NoError(test, err)
+	assert.True(test, r.MatchesPath("debug.log"), "debug.log should match")
+	assert.Equal(test, []string{"./", ".gitignore"}, walkForTest(test, root))
+}
+
+func TestCompileIgnoreFileByteOrderMark(test *testing.T) {
+	filename := writeFileToTestDir(test, "test.gitignore", "\ufeff*.log\r\nbuild/\r\n")
+
+	object, err := CompileIgnoreFile(filename)
+	assert.NoError(test, err)
+	assert.True(test, object.MatchesPath("debug.log"), "debug.log should match")
+	assert.True(test, object.MatchesPath("build/"), "build/ should match")
+}
+
+func TestAddPatternsFromFilesE(test *testing.T) {
+	dir := test.TempDir()
+	first := filepath.Join(dir, "first")
+	invalid := filepath.Join(dir, "invalid")
+	last := filepath.Join(dir, "last")
+	missing := filepath.Join(dir, "doesntexist")
+	for fpath, content := range map[string]string{first: "*.log\n", invalid: "abc\n[abc\n", last: "*.tmp\n"} {
+		if err := ioutil.WriteFile(fpath, []byte(content), os.ModePerm); err != nil {
+			test.Fatalf("failed to write to file %s: %s", fpath, err)
+		}
+	}
+
+	// A directory cannot be read as a file
+	object := New()
+	err := object.AddPatternsFromFilesE(first, missing, dir, invalid, last)
+	assert.True(test, object.MatchesPath("x.log"), "x.log should match")
+	assert.True(test, object.MatchesPath("abc"), "abc should match")
+	assert.True(test, object.MatchesPath("x.tmp"), "x.tmp should match")
+
+	var errs FileErrors
+	if assert.True(test, errors.As(err, &errs), "error should be FileErrors") && assert.Len(test, errs, 3) {
+		assert.True(test, os.IsNotExist(errs[0]), "error should be unknown file")
+		var pathErr *fs.PathError
+		if assert.True(test, errors.As(errs[1], &pathErr), "error should be a *fs.PathError") {
+			assert.Equal(test, dir, pathErr.Path)
+		}
+		assert.Equal(test, &ParseError{Source: invalid, Line: 2, Column: 1, Reason: "unterminated bracket expression"}, errs[2])
+	}
+
+	object = New(WithSkipMissingFiles())
+	err = object.AddPatternsFromFilesE(first, missing, dir)
+	if assert.True(test, errors.As(err, &errs), "error should be FileErrors") && assert.Len(test, errs, 1) {
+		assert.False(test, os.IsNotExist(errs[0]), "error should not be unknown file")
+	}
+	assert.True(test, object.MatchesPath("x.log"), "x.log should match")
+
+	object = New()
+	assert.NoError(test, object.AddPatternsFromFilesE(first, last))
+	assert.True(test, object.MatchesPath("x.tmp"), "x.tmp should match")
+}
+
+func TestAddPatternsFromFSE(test *testing.T) {
+	fsys := newMapFSForTest(map[string]string{
+		"first": "*.log\n",
+		"last":  "*.tmp\n",
+	})
+
+	object := New()
+	err := object.AddPatternsFromFSE(fsys, "first", "doesntexist", "last")
+	var errs FileErrors
+	if assert.True(test, errors.As(err, &errs), "error should be FileErrors") && assert.Len(test, errs, 1) {
+		assert.True(test, errors.Is(errs[0], fs.ErrNotExist), "error should be unknown file")
+	}
+	assert.True(test, object.MatchesPath("x.tmp"), "x.tmp should match")
+
+	object = New(WithSkipMissingFiles())
+	assert.NoError(test, object.AddPatternsFromFSE(fsys, "first", "doesntexist", "last"))
+	assert.True(test, object.MatchesPath("x.log"), "x.log should match")
+	assert.True(test, object.MatchesPath("x.tmp"), "x.tmp should match")
+}
+
+func TestWithSkipMissingFiles(test *testing.T) {
+	object, err := CompileIgnoreFile("doesntexist", WithSkipMissingFiles())
+	assert.NoError(test, err)
+	assert.NotNil(test, object, "object should not be nil")
+	assert.False(test, object.MatchesPath("doesntexist"), "doesntexist should not match")
+
+	object = CompileIgnoreLines("abc").AddPatternsFromFiles("doesntexist")
+	assert.True(test, object.MatchesPath("abc"), "abc should match")
+
+	filename := writeFileToTestDir(test, "test.gitignore", "def\n")
+	object = New(WithSkipMissingFiles()).AddPatternsFromFiles("doesntexist", filename)
+	assert.True(test, object.MatchesPath("def"), "def should match")
+}
@@ -0,0 +1,218 @@
+// Implement tests, ported from git's t/t3070-wildmatch.sh
+package ignore
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// wildmatchCase is a line of t3070-wildmatch.sh: whether `text` is
+// matched by `pattern`. Patterns and texts hold no "/", so that the
+// gitignore pattern matches the same way as git's wildmatch() does.
+type wildmatchCase struct {
+	match   bool
+	text    string
+	pattern string
+}
+
+func testWildmatch(test *testing.T, cases []wildmatchCase, opts ...Option) {
+	for _, c := range cases {
+		if runtime.GOOS == "windows" && strings.Contains(c.text, `\`) {
+			continue
+		}
+		object := New(opts...).AddPatternsFromLines(c.pattern)
+		if c.match {
+			assert.True(test, object.MatchesPath(c.text), "%q should match %q", c.pattern, c.text)
+		} else {
+			assert.False(test, object.MatchesPath(c.text), "%q should not match %q", c.pattern, c.text)
+		}
+	}
+}
+
+func TestWildmatchEscape(test *testing.T) {
+	testWildmatch(test, []wildmatchCase{
+		{true, "foo*", `foo\*`},
+		{false, "foobar", `foo\*bar`},
+		{true, `f\oo`, `f\\oo`},
+		{true, "[ab]", `\[ab]`},
+		{true, "[ab]", "[[]ab]"},
+		{false, "ab", `\[ab]`},
+	})
+}
+
+func TestWildmatchQuestionMark(test *testing.T) {
+	testWildmatch(test, []wildmatchCase{
+		{true, "foo", "???"},
+		{false, "foo", "??"},
+		{true, "ball", "*[al]?"},
+		{true, "?a?b", `\??\?b`},
+		{false, "?a?b", `\?\?\?b`},
+	})
+}
+
+func TestWildmatchBracket(test *testing.T) {
+	testWildmatch(test, []wildmatchCase{
+		{false, "ten", "[ten]"},
+		{true, "ten", "**[!te]"},
+		{false, "ten", "**[!ten]"},
+		{true, "ten", "t[a-g]n"},
+		{false, "ten", "t[!a-g]n"},
+		{true, "ton", "t[!a-g]n"},
+		{true, "ton", "t[^a-g]n"},
+		{true, "a]b", "a[]]b"},
+		{true, "a-b", "a[]-]b"},
+		{true, "a]b", "a[]-]b"},
+		{false, "aab", "a[]-]b"},
+		{true, "aab", "a[]a-]b"},
+		{true, "]", "]"},
+		// A reversed range matches nothing, but its start was already
+		// matched as a single character
+		{true, "bz", "b[z-a]"},
+		{false, "by", "b[z-a]"},
+		{false, "ba", "b[z-a]"},
+		{true, "bz", "b[!y-a]"},
+		{false, "by", "b[!y-a]"},
+	})
+}
+
+func TestWildmatchCharacterClass(test *testing.T) {
+	testWildmatch(test, []wildmatchCase{
+		{true, "a1B", "[[:alpha:]][[:digit:]][[:upper:]]"},
+		{false, "a", "[[:digit:][:upper:][:space:]]"},
+		{true, "A", "[[:digit:][:upper:][:space:]]"},
+		{true, "1", "[[:digit:][:upper:][:space:]]"},
+		{false, "1", "[[:digit:][:upper:][:spaci:]]"},
+		{true, " ", "[[:digit:][:upper:][:space:]]"},
+		{false, ".", "[[:digit:][:upper:][:space:]]"},
+		{true, ".", "[[:digit:][:punct:][:space:]]"},
+		{true, "5", "[[:xdigit:]]"},
+		{true, "f", "[[:xdigit:]]"},
+		{true, "D", "[[:xdigit:]]"},
+		{true, "_", "[[:alnum:][:alpha:][:blank:][:cntrl:][:digit:][:graph:][:lower:][:print:][:punct:][:space:][:upper:][:xdigit:]]"},
+		{true, ".", "[^[:alnum:][:alpha:][:blank:][:cntrl:][:digit:][:lower:][:space:][:upper:][:xdigit:]]"},
+		{true, "5", "[a-c[:digit:]x-z]"},
+		{true, "b", "[a-c[:digit:]x-z]"},
+		{true, "y", "[a-c[:digit:]x-z]"},
+		{false, "q", "[a-c[:digit:]x-z]"},
+	})
+}
+
+func TestWildmatchMalformedBracket(test *testing.T) {
+	testWildmatch(test, []wildmatchCase{
+		{true, "]", `[\\-^]`},
+		{false, "[", `[\\-^]`},
+		{true, "-", `[\-_]`},
+		{true, "]", `[\]]`},
+		{false, `\]`, `[\]]`},
+		{false, `\`, `[\]]`},
+		{false, "ab", "a[]b"},
+		{false, "a[]b", "a[]b"},
+		{false, "ab[", "ab["},
+		{false, "ab", "[!"},
+		{false, "ab", "[-"},
+		{true, "-", "[-]"},
+		{false, "-", "[a-"},
+		{false, "-", "[!a-"},
+		{true, "-", "[--A]"},
+		{true, "5", "[--A]"},
+		{true, " ", "[ --]"},
+		{true, "$", "[ --]"},
+		{true, "-", "[ --]"},
+		{false, "0", "[ --]"},
+		{true, "-", "[---]"},
+		{true, "-", "[------]"},
+		{false, "j", "[a-e-n]"},
+		{true, "-", "[a-e-n]"},
+		{true, "a", "[!------]"},
+		{false, "[", "[]-a]"},
+		{true, "^", "[]-a]"},
+		{false, "^", "[!]-a]"},
+		{true, "[", "[!]-a]"},
+		{true, "^", "[a^bc]"},
+		{true, "-b]", "[a-]b]"},
+		{false, `\`, `[\]`},
+		{true, `\`, `[\\]`},
+		{false, `\`, `[!\\]`},
+		{true, "G", `[A-\\]`},
+		{true, ",", "[,]"},
+		{true, ",", `[\\,]`},
+		{true, `\`, `[\\,]`},
+		{true, "-", "[,-.]"},
+		{false, "+", "[,-.]"},
+		{false, "-.]", "[,-.]"},
+		{true, "2", `[\1-\3]`},
+		{true, "3", `[\1-\3]`},
+		{false, "4", `[\1-\3]`},
+		{true, `\`, `[[-\]]`},
+		{true, "[", `[[-\]]`},
+		{true, "]", `[[-\]]`},
+		{false, "-", `[[-\]]`},
+	})
+}
+
+// Validate that bracket expressions never match a "/"
+func TestBracketSlash(test *testing.T) {
+	object := CompileIgnoreLines("a[!b]c", "d[--0]e", "f[/]g")
+
+	shouldMatch(test, object, "axc")
+	shouldNotMatch(test, object, "a/c")
+	shouldMatch(test, object, "d.e")
+	shouldNotMatch(test, object, "d/e")
+	shouldNotMatch(test, object, "f/g")
+}
+
+// Validate the common bracket expressions of gitignore templates
+func TestBracketTemplates(test *testing.T) {
+	object := CompileIgnoreLines("*.[oa]", "*.py[cod]", "[Tt]humbs.db", "*.sw[a-p]")
+
+	shouldMatch(test, object, "main.o")
+	shouldMatch(test, object, "lib/libfoo.a")
+	shouldNotMatch(test, object, "main.c")
+	shouldMatch(test, object, "a/b.pyc")
+	shouldNotMatch(test, object, "a/b.py")
+	shouldMatch(test, object, "Thumbs.db")
+	shouldMatch(test, object, "thumbs.db")
+	shouldMatch(test, object, ".main.go.swp")
+	shouldNotMatch(test, object, ".main.go.swx")
+}
+
+// The cases below have different results for wildmatch and iwildmatch
+var wildmatchCaseCases = []struct {
+	text, pattern string
+	match, imatch bool
+}{
+	{"a", "[A-Z]", false, true},
+	{"A", "[A-Z]", true, true},
+	{"A", "[a-z]", false, true},
+	{"a", "[a-z]", true, true},
+	{"a", "[[:upper:]]", false, true},
+	{"A", "[[:upper:]]", true, true},
+	{"A", "[[:lower:]]", false, true},
+	{"a", "[[:lower:]]", true, true},
+	{"A", "[B-Za]", false, true},
+	{"a", "[B-Za]", true, true},
+	{"A", "[B-a]", false, true},
+	{"a", "[B-a]", true, true},
+	{"z", "[Z-y]", false, true},
+	{"Z", "[Z-y]", true, true},
+	{"a", "[[:digit:][:upper:][:space:]]", false, true},
+}
+
+func TestWildmatchCase(test *testing.T) {
+	var cases []wildmatchCase
+	for _, c := range wildmatchCaseCases {
+		cases = append(cases, wildmatchCase{c.match, c.text, c.pattern})
+	}
+	testWildmatch(test, cases)
+}
+
+func TestIWildmatchCase(test *testing.T) {
+	var cases []wildmatchCase
+	for _, c := range wildmatchCaseCases {
+		cases = append(cases, wildmatchCase{c.imatch, c.text, c.pattern})
+	}
+	testWildmatch(test, cases, WithIgnoreCase())
+}
@@ -0,0 +1,223 @@
+package ignore
+
+import (
+	"strings"
+	"sync"
+)
+
+// patternKind classifies a pattern by its shape, so that the common ones
+// are matched without wildmatch.
+type patternKind int
+
+const (
+	// kindGeneral patterns are matched with wildmatch
+	kindGeneral patternKind = iota
+	// kindBasename is an unanchored literal, such as "node_modules",
+	// matching the basename of the path
+	kindBasename
+	// kindSuffix is an unanchored "*" followed by a literal, such as
+	// "*.log", matching the end of the basename of the path
+	kindSuffix
+	// kindPath is an anchored literal, such as "/dist" or "docs/build",
+	// matching the whole path
+	kindPath
+)
+
+// classify returns the kind of a pattern made of `tokens`, along with
+// its literal if it is not a kindGeneral pattern.
+func classify(tokens []token, anchored bool) (patternKind, string) {
+	switch {
+	case len(tokens) == 1 && tokens[0].kind == tokenLiteral:
+		if anchored {
+			return kindPath, tokens[0].text
+		}
+		return kindBasename, tokens[0].text
+	case len(tokens) == 2 && !anchored && tokens[0].kind == tokenStar && tokens[1].kind == tokenLiteral:
+		return kindSuffix, tokens[1].text
+	}
+	return kindGeneral, ""
+}
+
+// leadingLiteral returns the literal every path matched by `tokens`
+// starts with, if any. An unanchored pattern starts at the basename.
+func leadingLiteral(tokens []token) string {
+	if len(tokens) > 0 && tokens[0].kind == tokenLiteral {
+		return tokens[0].text
+	}
+	return ""
+}
+
+// trailingLiteral returns the literal every path matched by `tokens` ends
+// with, if any.
+func trailingLiteral(tokens []token) string {
+	if n := len(tokens); n > 0 && tokens[n-1].kind == tokenLiteral {
+		return tokens[n-1].text
+	}
+	return ""
+}
+
+// patternIndex finds the last pattern of a GitIgnore object which targets
+// a path. The literal patterns are looked up in hash sets by the basename,
+// the extensions or the whole path, so that only the other ones have to
+// be tried one by one, from the last one. Those ending with a literal are
+// only tried for the paths ending with the same byte. Each set maps a key
+// to the indexes of the patterns, in increasing order.
+type patternIndex struct {
+	basenames  map[string][]int
+	extensions map[string][]int // suffixes starting with a "."
+	paths      map[string][]int
+	suffixed   map[byte][]int // by the last byte of the trailing literal
+	others     []int
+
+	// negated is true if any of the patterns is negated
+	negated bool
+}
+
+// add indexes the pattern `ip`, at index `i` of the patterns. The keys are
+// case folded if `ignoreCase` is true.
+func (idx *patternIndex) add(i int, ip *ignorePattern, ignoreCase bool) {
+	if ip.negate {
+		idx.negated = true
+	}
+	lit := ip.literal
+	suffix := ip.suffix
+	if ignoreCase {
+		lit = foldKey(lit)
+		suffix = foldKey(suffix)
+	}
+	switch {
+	case ip.kind == kindBasename:
+		idx.basenames = addToSet(idx.basenames, lit, i)
+	case ip.kind == kindSuffix && lit[0] == '.':
+		idx.extensions = addToSet(idx.extensions, lit, i)
+	case ip.kind == kindPath:
+		idx.paths = addToSet(idx.paths, lit, i)
+	case suffix != "":
+		if idx.suffixed == nil {
+			idx.suffixed = map[byte][]int{}
+		}
+		last := suffix[len(suffix)-1]
+		idx.suffixed[last] = append(idx.suffixed[last], i)
+	default:
+		idx.others = append(idx.others, i)
+	}
+}
+
+func addToSet(set map[string][]int, key string, i int) map[string][]int {
+	if set == nil {
+		set = map[string][]int{}
+	}
+	set[key] = append(set[key], i)
+	return set
+}
+
+// foldBuffers holds the buffers used by last to fold the paths which are
+// too long for its buffer on the stack.
+var foldBuffers = sync.Pool{New: func() interface{} { return new([]byte) }}
+
+// last returns the index of the last of the patterns `patterns` which
+// targets the path `f` itself, if it is greater than `best`, or `best`
+// otherwise. Giving the best index found so far, e.g. for another path,
+// saves trying the patterns which come before it. The path is case folded
+// if `ignoreCase` is true.
+func (idx *patternIndex) last(patterns []*ignorePattern, f string, isDir, ignoreCase bool, best int) int {
+	key := f
+	if ignoreCase {
+		// The key is not retained, so it is folded into a buffer on the
+		// stack, or from a pool for long paths
+		var buf [128]byte
+		if len(f) <= len(buf) {
+			if b, ok := appendFoldKey(buf[:0], f); ok {
+				key = bytesToString(b)
+			}
+		} else {
+			p := foldBuffers.Get().(*[]byte)
+			defer foldBuffers.Put(p)
+			if b, ok := appendFoldKey((*p)[:0], f); ok {
+				*p = b
+				key = bytesToString(b)
+			}
+		}
+	}
+	base := key[strings.LastIndexByte(key, '/')+1:]
+
+	best = lastInSet(patterns, idx.basenames[base], isDir, best)
+	best = lastInSet(patterns, idx.paths[key], isDir, best)
+	if idx.extensions != nil {
+		for i := strings.IndexByte(base, '.'); i >= 0; {
+			best = lastInSet(patterns, idx.extensions[base[i:]], isDir, best)
+			j := strings.IndexByte(base[i+1:], '.')
+			if j < 0 {
+				break
+			}
+			i += j + 1
+		}
+	}
+
+	// Only the patterns after the best one so far can take precedence,
+	// the first one matching from the end wins
+	var suffixed []int
+	if len(key) > 0 {
+		suffixed = idx.suffixed[key[len(key)-1]]
+	}
+	others := idx.others
+	for i, j := len(suffixed)-1, len(others)-1; ; {
+		var k int
+		switch {
+		case i >= 0 && (j < 0 || suffixed[i] > others[j]):
+			k = suffixed[i]
+			i--
+		case j >= 0:
+			k = others[j]
+			j--
+		default:
+			return best
+		}
+		if k <= best {
+			return best
+		}
+		if patterns[k].matches(f, isDir) {
+			return k
+		}
+	}
+}
+
+// lastInSet returns the last index of `set` whose pattern applies to the
+// path, if it is greater than `best`, or `best` otherwise.
+func lastInSet(patterns []*ignorePattern, set []int, isDir bool, best int) int {
+	for j := len(set) - 1; j >= 0 && set[j] > best; j-- {
+		if isDir || !patterns[set[j]].dirOnly {
+			return set[j]
+		}
+	}
+	return best
+}
+
+// foldKey returns `s` with its upper case ASCII letters made lower case,
+// such that two strings are equal regardless of case, as git's
+// core.ignoreCase understands it, if their keys are equal. It returns `s`
+// itself, without allocating, if it has no upper case ASCII letter.
+func foldKey(s string) string {
+	if b, ok := appendFoldKey(nil, s); ok {
+		return string(b)
+	}
+	return s
+}
+
+// appendFoldKey appends the key of `s`, as returned by foldKey, to `dst`.
+// It returns false, without appending anything, if the key is `s` itself.
+func appendFoldKey(dst []byte, s string) ([]byte, bool) {
+	i := 0
+	for i < len(s) && !('A' <= s[i] && s[i] <= 'Z') {
+		i++
+	}
+	if i == len(s) {
+		return dst, false
+	}
+
+	dst = append(dst, s[:i]...)
+	for ; i < len(s); i++ {
+		dst = append(dst, lowerASCII(s[i]))
+	}
+	return dst, true
+}
@@ -0,0 +1,170 @@
+package ignore
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(test *testing.T) {
+	for pattern, expected := range map[string]patternKind{
+		"node_modules": kindBasename,
+		`\!important`:  kindBasename,
+		"*.log":        kindSuffix,
+		"*~":           kindSuffix,
+		"/dist":        kindPath,
+		"docs/build/":  kindPath,
+		"/*.log":       kindGeneral,
+		"*.log*":       kindGeneral,
+		"a*b":          kindGeneral,
+		"file?.txt":    kindGeneral,
+		"**/foo":       kindGeneral,
+		"foo/**":       kindGeneral,
+	} {
+		ip, _ := getPatternFromLine(pattern, false)
+		if assert.NotNil(test, ip, pattern) {
+			assert.Equal(test, expected, ip.kind, pattern)
+		}
+	}
+}
+
+func TestFoldKey(test *testing.T) {
+	assert.Equal(test, "abc/def.log", foldKey("abc/def.log"))
+	assert.Equal(test, "abc/def.log", foldKey("ABC/Def.LOG"))
+	assert.Equal(test, "σx", foldKey("σX"))
+	assert.NotEqual(test, foldKey("kelvin"), foldKey("\u212Aelvin"))
+	assert.NotEqual(test, foldKey("ü.txt"), foldKey("Ü.TXT"))
+	assert.Equal(test, "a\xffb", foldKey("A\xffB"))
+	assert.NotEqual(test, foldKey("a\xffb"), foldKey("a\xfeb"))
+
+	b, ok := appendFoldKey([]byte("x:"), "Abc")
+	assert.True(test, ok)
+	assert.Equal(test, "x:abc", string(b))
+	b, ok = appendFoldKey([]byte("x:"), "abc")
+	assert.False(test, ok)
+	assert.Equal(test, "x:", string(b))
+}
+
+func TestPatternIndex(test *testing.T) {
+	object := CompileIgnoreLines("*.log", "debug.log", "!*.log", "/build", "build/", "*.tar.gz", "!keep.tar.gz", "a/b")
+
+	result := object.Match("debug.log")
+	if assert.NotNil(test, result) {
+		assert.Equal(test, "!*.log", result.Pattern)
+	}
+	assert.True(test, object.MatchesPath("x.tar.gz"), "x.tar.gz should match")
+	assert.False(test, object.MatchesPath("keep.tar.gz"), "keep.tar.gz should not match")
+	assert.False(test, object.MatchesPath("x.gz"), "x.gz should not match")
+	assert.True(test, object.MatchesPath("build"), "build should match")
+	assert.True(test, object.MatchesPath("x/build/"), "x/build/ should match")
+	assert.False(test, object.MatchesPath("x/build"), "x/build should not match")
+	assert.True(test, object.MatchesPath("a/b/c"), "a/b/c should match")
+	assert.False(test, object.MatchesPath("x/a/b"), "x/a/b should not match")
+}
+
+// Validate that the index finds the same pattern as trying every pattern
+// in turn with wildmatch
+func TestPatternIndexRandom(test *testing.T) {
+	pieces := []string{"a", "B", ".", "/", "*", "?", "[ab]", "x.y", "*.", "K"}
+	texts := []string{"a", "b", "A", ".", "/", "x", "y", "k", "K"}
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 2000; i++ {
+		var lines []string
+		for n := rng.Intn(8) + 1; n > 0; n-- {
+			var p strings.Builder
+			if rng.Intn(4) == 0 {
+				p.WriteString("!")
+			}
+			for m := rng.Intn(3) + 1; m > 0; m-- {
+				p.WriteString(pieces[rng.Intn(len(pieces))])
+			}
+			lines = append(lines, p.String())
+		}
+
+		for _, ignoreCase := range []bool{false, true} {
+			var opts []Option
+			if ignoreCase {
+				opts = append(opts, WithIgnoreCase())
+			}
+			object := New(opts...).AddPatternsFromLines(lines...)
+
+			for j := 0; j < 10; j++ {
+				var t strings.Builder
+				for n := rng.Intn(6) + 1; n > 0; n-- {
+					t.WriteString(texts[rng.Intn(len(texts))])
+				}
+				text := strings.Trim(t.String(), "/")
+				isDir := rng.Intn(2) == 0
+
+				var expected *ignorePattern
+				for k := len(object.patterns) - 1; k >= 0; k-- {
+					ip := object.patterns[k]
+					if (isDir || !ip.dirOnly) && wildmatch(ip.tokens, text, ip.anchored, ignoreCase) {
+						expected = ip
+						break
+					}
+				}
+				actual := object.lastMatch(text, isDir)
+				if !assert.Equal(test, expected, actual, "%q against %q, ignoreCase: %v", lines, text, ignoreCase) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Validate that trying the patterns from the last one, and stopping early,
+// finds the same pattern as trying every pattern against the path and its
+// parents
+func TestMatchRandom(test *testing.T) {
+	pieces := []string{"a", "b", ".", "/", "*", "?", "**", "x.y", "*.", "/"}
+	texts := []string{"a", "b", ".", "/", "x", "y"}
+	rng := rand.New(rand.NewSource(2))
+
+	for i := 0; i < 2000; i++ {
+		var lines []string
+		negate := rng.Intn(2) == 0
+		for n := rng.Intn(8) + 1; n > 0; n-- {
+			var p strings.Builder
+			if negate && rng.Intn(3) == 0 {
+				p.WriteString("!")
+			}
+			for m := rng.Intn(3) + 1; m > 0; m-- {
+				p.WriteString(pieces[rng.Intn(len(pieces))])
+			}
+			lines = append(lines, p.String())
+		}
+		object := CompileIgnoreLines(lines...)
+
+		for j := 0; j < 10; j++ {
+			var t strings.Builder
+			for n := rng.Intn(8) + 1; n > 0; n-- {
+				t.WriteString(texts[rng.Intn(len(texts))])
+			}
+			text := strings.Trim(t.String(), "/")
+			if text == "" || strings.Contains(text, "//") {
+				continue
+			}
+			isDir := rng.Intn(2) == 0
+
+			var expected *ignorePattern
+			for _, ip := range object.patterns {
+				matched := ip.matches(text, isDir)
+				for k := 0; k < len(text) && !matched; k++ {
+					matched = text[k] == '/' && ip.matches(text[:k], true)
+				}
+				if matched {
+					expected = ip
+				}
+			}
+			if !assert.Equal(test, expected, object.match(text, isDir, true), "%q against %q", lines, text) {
+				return
+			}
+			ignored := expected != nil && !expected.negate
+			assert.Equal(test, ignored, object.MatchesPathIsDir(text, isDir), "%q against %q", lines, text)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !race
+// +build !race
+
+package ignore
+
+// raceEnabled is true when testing with the race detector, which makes
+// sync.Pool drop some of its items and so allocate.
+const raceEnabled = false
@@ -0,0 +1,115 @@
+package ignore
+
+import (
+	"strings"
+)
+
+// tokenKind identifies the elements of a gitignore pattern.
+type tokenKind int
+
+const (
+	// tokenLiteral is a run of characters matched as is
+	tokenLiteral tokenKind = iota
+	// tokenStar is "*", any run of characters but "/"
+	tokenStar
+	// tokenQuestion is "?", any single character but "/"
+	tokenQuestion
+	// tokenClass is a bracket expression, such as "[a-z]"
+	tokenClass
+	// tokenDirs is a leading "**/" or a "/**/", zero or more directories
+	tokenDirs
+	// tokenSubtree is a trailing "/**", anything inside, but not the path itself
+	tokenSubtree
+	// tokenAny is a pattern made of "**" alone, any path
+	tokenAny
+)
+
+// token is an element of a gitignore pattern.
+type token struct {
+	kind  tokenKind
+	text  string     // the characters of a tokenLiteral
+	class *charClass // the set of a tokenClass
+}
+
+// tokenize splits the pattern `p`, stripped of its leading "!" and
+// trailing "/", into tokens, following git's wildmatch. Any character
+// escaped with a backslash is a literal. It returns a *ParseError, whose
+// Column is relative to `p`, if the pattern is invalid. The tokens are
+// still returned if the error is not fatal, as for an invalid "**".
+func tokenize(p string) ([]token, *ParseError) {
+	var tokens []token
+	var perr *ParseError
+	var literal strings.Builder
+
+	flush := func() {
+		if literal.Len() > 0 {
+			tokens = append(tokens, token{kind: tokenLiteral, text: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(p); i++ {
+		switch p[i] {
+		case '\\':
+			if i+1 == len(p) {
+				return nil, &ParseError{Column: i + 1, Reason: "trailing backslash"}
+			}
+			i++
+			literal.WriteByte(p[i])
+		case '?':
+			flush()
+			tokens = append(tokens, token{kind: tokenQuestion})
+		case '[':
+			cc, n, reason := parseBracket(p[i:])
+			if reason != "" {
+				return nil, &ParseError{Column: i + 1, Reason: reason}
+			}
+			flush()
+			tokens = append(tokens, token{kind: tokenClass, class: cc})
+			i += n - 1
+		case '*':
+			j := i
+			for j < len(p) && p[j] == '*' {
+				j++
+			}
+			start := i == 0 || p[i-1] == '/'
+			end := j == len(p) || p[j] == '/'
+			if j-i == 1 || !start || !end {
+				if j-i > 1 && perr == nil {
+					// [Rule 9] Other consecutive asterisks behave like "*"
+					perr = &ParseError{Column: i + 1, Reason: `invalid "**", it must be a whole path component`}
+				}
+				flush()
+				tokens = append(tokens, token{kind: tokenStar})
+				i = j - 1
+				continue
+			}
+			if j-i > 2 && perr == nil {
+				perr = &ParseError{Column: i + 1, Reason: `invalid "**", it must be a whole path component`}
+			}
+
+			switch {
+			case j < len(p):
+				// "**/", which also swallows the following "/"
+				flush()
+				tokens = append(tokens, token{kind: tokenDirs})
+				j++
+			case literal.Len() > 0:
+				// "/**", which swallows the preceding "/"
+				s := literal.String()
+				literal.Reset()
+				literal.WriteString(s[:len(s)-1])
+				flush()
+				tokens = append(tokens, token{kind: tokenSubtree})
+			default:
+				// "**" alone, or following another "**/"
+				tokens = append(tokens, token{kind: tokenAny})
+			}
+			i = j - 1
+		default:
+			literal.WriteByte(p[i])
+		}
+	}
+	flush()
+	return tokens, perr
+}
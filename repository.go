@@ -0,0 +1,321 @@
+package ignore
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Repository matches paths against every .gitignore file of a work tree,
+// each one being relative to the directory it is found in, along with the
+// exclude files of the repository and of the user. Like git, the
+// patterns of a deeper file take precedence over the ones of the files
+// above it, and a path is ignored as soon as one of its parent
+// directories is ignored.
+type Repository struct {
+	root string
+
+	// fsys is the file system of the work tree, or nil for the OS one.
+	fsys fs.FS
+
+	// excludes holds the exclude files, by increasing precedence.
+	excludes []*GitIgnore
+
+	// ignores holds the .gitignore files by the path of their directory
+	// relative to the top of the work tree, "" for the top one, as given
+	// by ignoreKey.
+	ignores map[string]*GitIgnore
+
+	// ignoreCase is true if the directories are looked up regardless of
+	// case, as the patterns are matched.
+	ignoreCase bool
+
+	// opts are the options of every file, including the ones set from
+	// the git config.
+	opts []Option
+}
+
+// NewRepository loads the .gitignore files of the work tree at `root`,
+// skipping the .git directory and the directories which are ignored,
+// since git never reads the files inside of them, along with the ones
+// which cannot be read. They take precedence
+// over the exclude files returned by LoadExcludes, which are loaded
+// first. The git config is read with LoadConfig, and WithIgnoreCase
+// applies if core.ignoreCase is true. The options apply to every file.
+func NewRepository(root string, opts ...Option) (*Repository, error) {
+	r, err := openRepository(root, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.walk(nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// openRepository returns a Repository holding the exclude files of the
+// work tree at `root`, before any .gitignore file is loaded.
+func openRepository(root string, opts []Option) (*Repository, error) {
+	config, err := LoadConfig(root)
+	if err != nil {
+		return nil, err
+	}
+	opts = configOptions(config, opts)
+	excludes, err := loadExcludes(root, config, opts)
+	if err != nil {
+		return nil, err
+	}
+	return newRepository(root, nil, excludes, opts), nil
+}
+
+// newRepository returns a Repository holding the exclude files `excludes`,
+// before any .gitignore file is loaded.
+func newRepository(root string, fsys fs.FS, excludes []*GitIgnore, opts []Option) *Repository {
+	return &Repository{
+		root:       root,
+		fsys:       fsys,
+		excludes:   excludes,
+		ignores:    make(map[string]*GitIgnore),
+		ignoreCase: New(opts...).ignoreCase,
+		opts:       opts[:len(opts):len(opts)],
+	}
+}
+
+// NewRepositoryFS is like NewRepository, but loads the .gitignore files
+// of the work tree at the root of the file system `fsys`, along with its
+// .git/info/exclude file if .git is a directory. Neither the git config nor the files of the user
+// are read, so the options must be given.
+func NewRepositoryFS(fsys fs.FS, opts ...Option) (*Repository, error) {
+	// The .git file of a linked work tree points to a directory which may
+	// be out of the file system
+	var excludes []*GitIgnore
+	if fi, err := fs.Stat(fsys, ".git"); err == nil && fi.IsDir() {
+		gi, err := CompileIgnoreFS(fsys, ".git/info/exclude", opts...)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if gi != nil {
+			excludes = append(excludes, gi)
+		}
+	}
+
+	r := newRepository(".", fsys, excludes, opts)
+	if err := r.walk(nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// walkFrame is a directory entered by a walk.
+type walkFrame struct {
+	rel string // the slash-separated path relative to the top of the work tree
+
+	// ignores holds the ignore files of the directory and of its parents,
+	// by increasing precedence.
+	ignores []*GitIgnore
+}
+
+// walk walks the work tree, loading the .gitignore file of each directory
+// before entering it. Unless `fn` is nil, it is called for each file and
+// directory which is not ignored, as described by Walk.
+func (r *Repository) walk(fn WalkFunc) error {
+	// frames holds the directory of the current entry and its parents,
+	// from the top one
+	var frames []walkFrame
+	walkFn := func(fpath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if fn == nil {
+				// Like git, skip the directories which cannot be read
+				if fpath == r.root {
+					return err
+				}
+				return filepath.SkipDir
+			}
+			return fn(fpath, d, err)
+		}
+		if fn == nil && !d.IsDir() {
+			return nil
+		}
+		if fpath != r.root && d.Name() == ".git" {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(r.root, fpath)
+		if err != nil {
+			return err
+		}
+		ignores := r.excludes
+		if rel == "." {
+			rel = ""
+		} else {
+			rel = filepath.ToSlash(rel)
+			parent := path.Dir(rel)
+			if parent == "." {
+				parent = ""
+			}
+			for len(frames) > 1 && frames[len(frames)-1].rel != parent {
+				frames = frames[:len(frames)-1]
+			}
+			ignores = frames[len(frames)-1].ignores
+
+			// The parent directories are not ignored, or they would not be
+			// entered
+			if ip := lastMatchIn(ignores, rel, d.IsDir()); ip != nil && !ip.negate {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		if fn != nil {
+			if err := fn(fpath, d, nil); err != nil {
+				return err
+			}
+		}
+		if d.IsDir() {
+			gi, err := r.loadIgnoreFile(fpath, rel)
+			if err != nil {
+				return err
+			}
+			if gi != nil {
+				r.ignores[r.ignoreKey(gi.base)] = gi
+				ignores = append(ignores[:len(ignores):len(ignores)], gi)
+			}
+			frames = append(frames, walkFrame{rel: rel, ignores: ignores})
+		}
+		return nil
+	}
+	if r.fsys != nil {
+		return fs.WalkDir(r.fsys, r.root, walkFn)
+	}
+	return filepath.WalkDir(r.root, walkFn)
+}
+
+// loadIgnoreFile compiles the .gitignore file of the directory `dir`, whose
+// path relative to the top of the work tree is `rel`. It returns nil if
+// there is none.
+func (r *Repository) loadIgnoreFile(dir, rel string) (*GitIgnore, error) {
+	var gi *GitIgnore
+	var err error
+	opts := append(r.opts, WithBase(rel))
+	if r.fsys != nil {
+		gi, err = CompileIgnoreFS(r.fsys, path.Join(dir, ".gitignore"), opts...)
+	} else {
+		gi, err = CompileIgnoreFile(filepath.Join(dir, ".gitignore"), opts...)
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return gi, nil
+}
+
+// ignoreKey returns the key of the .gitignore file of the directory `dir`,
+// as given by WithBase, in the ignores map.
+func (r *Repository) ignoreKey(dir string) string {
+	if r.ignoreCase {
+		return foldKey(dir)
+	}
+	return dir
+}
+
+// Root returns the top directory of the work tree, which is "." for the
+// repositories of a file system.
+func (r *Repository) Root() string {
+	return r.root
+}
+
+// MatchesPath returns true if the path `f`, relative to the top of the
+// work tree, is ignored. A trailing slash marks `f` as a directory.
+func (r *Repository) MatchesPath(f string) bool {
+	return r.Match(f).Ignored()
+}
+
+// MatchesPathIsDir returns true if the path `f`, relative to the top of
+// the work tree, is ignored. It is a directory if `isDir` is true.
+func (r *Repository) MatchesPathIsDir(f string, isDir bool) bool {
+	return r.MatchIsDir(f, isDir).Ignored()
+}
+
+// Match returns the pattern which decides whether the path `f`, relative
+// to the top of the work tree, is ignored, or nil if no pattern targets
+// it. A trailing slash marks `f` as a directory.
+func (r *Repository) Match(f string) *MatchResult {
+	isDir := strings.HasSuffix(f, "/") || strings.HasSuffix(f, string(os.PathSeparator))
+	return r.MatchIsDir(f, isDir)
+}
+
+// MatchIsDir is like Match, but the caller states whether the path `f`
+// is a directory.
+func (r *Repository) MatchIsDir(f string, isDir bool) *MatchResult {
+	return newMatchResult(r.match(f, isDir))
+}
+
+// match returns the pattern which decides whether the path `f` is
+// ignored, or nil if no pattern targets it.
+func (r *Repository) match(f string, isDir bool) *ignorePattern {
+	f = cleanPath(f)
+	if f == "" {
+		return nil
+	}
+
+	// Like git, stop at the first excluded parent directory
+	for i := 0; i < len(f); i++ {
+		if f[i] != '/' {
+			continue
+		}
+		if ip := r.lastMatch(f[:i], true); ip != nil && !ip.negate {
+			return ip
+		}
+	}
+	return r.lastMatch(f, isDir)
+}
+
+// lastMatch returns the pattern of the file with the highest precedence
+// which targets the path `f` itself, not taking its parent directories
+// into account. Only the .gitignore files of these directories are looked
+// up, from the deepest one.
+func (r *Repository) lastMatch(f string, isDir bool) *ignorePattern {
+	for i := len(f); i > 0 && len(r.ignores) > 0; {
+		i = strings.LastIndexByte(f[:i], '/')
+		dir := ""
+		if i > 0 {
+			dir = f[:i]
+		}
+		if gi := r.ignores[r.ignoreKey(dir)]; gi != nil {
+			if ip := gi.lastMatch(f[i+1:], isDir); ip != nil {
+				return ip
+			}
+		}
+	}
+	return lastMatchIn(r.excludes, f, isDir)
+}
+
+// lastMatchIn returns the pattern of the file of `ignores`, by increasing
+// precedence, with the highest precedence which targets the path `f`
+// itself, not taking its parent directories into account.
+func lastMatchIn(ignores []*GitIgnore, f string, isDir bool) *ignorePattern {
+	for i := len(ignores) - 1; i >= 0; i-- {
+		gi := ignores[i]
+		rel := f
+		if gi.base != "" {
+			var ok bool
+			if rel, ok = gi.trimBase(f); !ok {
+				continue
+			}
+		}
+		if ip := gi.lastMatch(rel, isDir); ip != nil {
+			return ip
+		}
+	}
+	return nil
+}
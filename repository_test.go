@@ -0,0 +1,237 @@
+package ignore
+
+import (
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTreeToTestDir is a helper function to setup a temp directory for
+// the test holding the files of "tree", which maps slash-separated paths
+// to contents. A path ending with a slash is an empty directory.
+func writeTreeToTestDir(test *testing.T, tree map[string]string) string {
+	dir := test.TempDir()
+	writeTree(test, dir, tree)
+	return dir
+}
+
+// writeTree is a helper function to write the files of "tree" within "dir"
+func writeTree(test *testing.T, dir string, tree map[string]string) {
+	for name, content := range tree {
+		fpath := filepath.Join(dir, filepath.FromSlash(name))
+		if name[len(name)-1] == '/' {
+			if err := os.MkdirAll(fpath, os.ModePerm); err != nil {
+				test.Fatalf("failed to create directory %s: %s", fpath, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+			test.Fatalf("failed to create directory %s: %s", filepath.Dir(fpath), err)
+		}
+		if err := ioutil.WriteFile(fpath, []byte(content), os.ModePerm); err != nil {
+			test.Fatalf("failed to write to file %s: %s", fpath, err)
+		}
+	}
+}
+
+func TestNewRepository(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeTreeToTestDir(test, map[string]string{
+		".gitignore":           "*.log\n/build\nvendor/\n",
+		"a/.gitignore":         "!keep.log\n/local\n",
+		"a/b/.gitignore":       "*.tmp\nkeep.log\n",
+		"build/.gitignore":     "!*\n",
+		"vendor/x/.gitignore":  "!*.log\n",
+		".git/info/.gitignore": "*\n",
+		"a/b/c/file.txt":       "",
+	})
+
+	r, err := NewRepository(root)
+	assert.NoError(test, err)
+	assert.Equal(test, root, r.Root())
+
+	// .git and ignored directories are not read
+	assert.Len(test, r.ignores, 3)
+
+	assert.True(test, r.MatchesPath("debug.log"), "debug.log should match")
+	assert.True(test, r.MatchesPath("x/debug.log"), "x/debug.log should match")
+	assert.False(test, r.MatchesPath("a/keep.log"), "a/keep.log should not match")
+	assert.True(test, r.MatchesPath("a/other.log"), "a/other.log should match")
+
+	// Deeper files take precedence
+	assert.True(test, r.MatchesPath("a/b/keep.log"), "a/b/keep.log should match")
+	assert.True(test, r.MatchesPath("a/b/c/keep.log"), "a/b/c/keep.log should match")
+	assert.False(test, r.MatchesPath("a/x/keep.log"), "a/x/keep.log should not match")
+
+	// Patterns are relative to their file
+	assert.True(test, r.MatchesPath("a/local"), "a/local should match")
+	assert.False(test, r.MatchesPath("local"), "local should not match")
+	assert.False(test, r.MatchesPath("a/x/local"), "a/x/local should not match")
+	assert.True(test, r.MatchesPath("a/b/c/x.tmp"), "a/b/c/x.tmp should match")
+	assert.False(test, r.MatchesPath("a/x.tmp"), "a/x.tmp should not match")
+
+	// Files inside an ignored directory cannot be re-included
+	assert.True(test, r.MatchesPath("build/x.txt"), "build/x.txt should match")
+	assert.True(test, r.MatchesPath("vendor/x/y.log"), "vendor/x/y.log should match")
+	assert.False(test, r.MatchesPathIsDir("vendor", false), "vendor file should not match")
+
+	result := r.Match("a/b/keep.log")
+	if assert.NotNil(test, result) {
+		assert.Equal(test, "keep.log", result.Pattern)
+		assert.Equal(test, filepath.Join(root, "a", "b", ".gitignore"), result.Source)
+		assert.Equal(test, 2, result.Line)
+	}
+
+	result = r.MatchIsDir("vendor/x/y.log", false)
+	if assert.NotNil(test, result) {
+		assert.Equal(test, "vendor/", result.Pattern)
+		assert.Equal(test, filepath.Join(root, ".gitignore"), result.Source)
+	}
+	assert.Nil(test, r.Match("a/b/c/file.txt"))
+}
+
+func TestNewRepositoryIgnoreCase(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeTreeToTestDir(test, map[string]string{
+		"Sub/.gitignore": "Build/\n",
+	})
+
+	r, err := NewRepository(root, WithIgnoreCase())
+	assert.NoError(test, err)
+	assert.True(test, r.MatchesPath("sub/build/"), "sub/build/ should match")
+}
+
+// Validate that only the .gitignore files of the parent directories of a
+// path apply to it
+func TestNewRepositoryParentDirectories(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeTreeToTestDir(test, map[string]string{
+		"a/.gitignore":    "x\n",
+		"ab/c/.gitignore": "y\n",
+	})
+
+	r, err := NewRepository(root)
+	assert.NoError(test, err)
+	assert.True(test, r.MatchesPath("a/x"), "a/x should match")
+	assert.True(test, r.MatchesPath("a/b/x"), "a/b/x should match")
+	assert.False(test, r.MatchesPath("ab/x"), "ab/x should not match")
+	assert.True(test, r.MatchesPath("ab/c/d/y"), "ab/c/d/y should match")
+	assert.False(test, r.MatchesPath("ab/y"), "ab/y should not match")
+	assert.False(test, r.MatchesPath("a/b/c/y"), "a/b/c/y should not match")
+}
+
+// Validate that, like git, a trailing "/**" does not match the path itself
+func TestNewRepositoryTrailingDoubleStar(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeTreeToTestDir(test, map[string]string{
+		".gitignore": "*/**\n",
+		"a":          "",
+	})
+	r, err := NewRepository(root)
+	assert.NoError(test, err)
+	assert.False(test, r.MatchesPath(".gitignore"), ".gitignore should not match")
+	assert.False(test, r.MatchesPath("README"), "README should not match")
+	assert.False(test, r.MatchesPath("src/"), "src/ should not match")
+	assert.True(test, r.MatchesPath("src/lib.go"), "src/lib.go should match")
+
+	root = writeTreeToTestDir(test, map[string]string{
+		".gitignore": "a/**\nabc/**\n!abc/keep\n",
+		"abc/keep":   "",
+	})
+	r, err = NewRepository(root)
+	assert.NoError(test, err)
+	assert.False(test, r.MatchesPathIsDir("a", false), "a file should not match")
+	assert.False(test, r.MatchesPath("abc/"), "abc/ should not match")
+	assert.False(test, r.MatchesPath("abc/keep"), "abc/keep should not match")
+	assert.True(test, r.MatchesPath("abc/other"), "abc/other should match")
+}
+
+func TestNewRepositoryDoesntExist(test *testing.T) {
+	r, err := NewRepository(filepath.Join(test.TempDir(), "doesntexist"))
+	assert.Nil(test, r, "repository should be nil")
+	assert.True(test, os.IsNotExist(err), "error should be unknown file / dir")
+}
+
+func TestNewRepositoryFS(test *testing.T) {
+	fsys := newMapFSForTest(map[string]string{
+		".gitignore":           "*.log\n/build\n",
+		".git/info/exclude":    "*.tmp\n",
+		".git/info/.gitignore": "*\n",
+		"a/.gitignore":         "!keep.log\n/local\n",
+		"build/.gitignore":     "!*\n",
+	})
+
+	r, err := NewRepositoryFS(fsys, WithIgnoreCase())
+	assert.NoError(test, err)
+	assert.Equal(test, ".", r.Root())
+	assert.Len(test, r.excludes, 1)
+	assert.Len(test, r.ignores, 2)
+
+	assert.True(test, r.MatchesPath("debug.LOG"), "debug.LOG should match")
+	assert.True(test, r.MatchesPath("x.tmp"), "x.tmp should match")
+	assert.False(test, r.MatchesPath("a/keep.log"), "a/keep.log should not match")
+	assert.True(test, r.MatchesPath("a/local"), "a/local should match")
+	assert.True(test, r.MatchesPath("build/x.txt"), "build/x.txt should match")
+
+	result := r.Match("a/local")
+	if assert.NotNil(test, result) {
+		assert.Equal(test, "a/.gitignore", result.Source)
+	}
+}
+
+// Validate that a .git file, as found in a linked work tree, is not read
+// as a directory
+func TestNewRepositoryFSGitFile(test *testing.T) {
+	root := writeTreeToTestDir(test, map[string]string{
+		".git":       "gitdir: /elsewhere/.git/worktrees/wt\n",
+		".gitignore": "*.log\n",
+	})
+
+	r, err := NewRepositoryFS(os.DirFS(root))
+	assert.NoError(test, err)
+	assert.Empty(test, r.excludes)
+	assert.True(test, r.MatchesPath("debug.log"), "debug.log should match")
+}
+
+// deniedFS is a file system which fails to read the directory `denied`
+type deniedFS struct {
+	fstest.MapFS
+	denied string
+}
+
+func (fsys deniedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == fsys.denied {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrPermission}
+	}
+	return fsys.MapFS.ReadDir(name)
+}
+
+func TestNewRepositoryFSUnreadableDirectory(test *testing.T) {
+	fsys := deniedFS{MapFS: newMapFSForTest(map[string]string{
+		".gitignore":       "*.log\n",
+		"denied/file":      "",
+		"a/.gitignore":     "*.tmp\n",
+		"a/b/.gitignore":   "!keep.tmp\n",
+		"z/sub/.gitignore": "*.txt\n",
+	}), denied: "a"}
+
+	// The directory is skipped, and the others are still read
+	r, err := NewRepositoryFS(fsys)
+	assert.NoError(test, err)
+	assert.True(test, r.MatchesPath("debug.log"), "debug.log should match")
+	assert.True(test, r.MatchesPath("a/x.tmp"), "a/x.tmp should match")
+	assert.True(test, r.MatchesPath("a/b/keep.tmp"), "a/b/keep.tmp should match")
+	assert.True(test, r.MatchesPath("z/sub/x.txt"), "z/sub/x.txt should match")
+
+	// The error of the top directory is still returned
+	fsys.denied = "."
+	r, err = NewRepositoryFS(fsys)
+	assert.Nil(test, r, "repository should be nil")
+	assert.True(test, errors.Is(err, fs.ErrPermission), "error should be a denied permission")
+}
@@ -0,0 +1,23 @@
+package ignore
+
+import (
+	"io/fs"
+)
+
+// WalkFunc is the type of the function called by Walk for each file or
+// directory which is not ignored. It behaves like fs.WalkDirFunc.
+type WalkFunc func(path string, d fs.DirEntry, err error) error
+
+// Walk walks the work tree at `root` like filepath.WalkDir, calling `fn`
+// for each file or directory which is not ignored, `root` included. Like
+// NewRepository, it reads the exclude files and the git config first, then
+// loads the .gitignore file of each directory as it descends. The .git
+// directory and the ignored directories are skipped without calling `fn`,
+// so that nothing inside of them is read.
+func Walk(root string, fn WalkFunc, opts ...Option) error {
+	r, err := openRepository(root, opts)
+	if err != nil {
+		return err
+	}
+	return r.walk(fn)
+}
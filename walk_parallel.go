@@ -0,0 +1,444 @@
+package ignore
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// readAhead is the number of directories per worker which a sorted walk
+// reads ahead of the callback.
+const readAhead = 32
+
+// ParallelWalker walks a work tree like Walk, reading its directories and
+// matching their entries with a bounded pool of goroutines. The ignore
+// files of a directory are compiled once and shared by its subdirectories.
+type ParallelWalker struct {
+	// Workers is the number of directories read at once. It defaults to
+	// runtime.GOMAXPROCS(0).
+	Workers int
+
+	// Sorted makes the walker call back from a single goroutine, in the
+	// lexical order of Walk. Otherwise the callback is called concurrently
+	// and in no particular order, but for a directory always before its
+	// entries. The entries of the directories read ahead of the callback
+	// are kept in memory until it gets to them, which the walker bounds to
+	// a few dozen directories per worker: the workers wait for a slow
+	// callback to catch up.
+	Sorted bool
+
+	// Options apply to every ignore file.
+	Options []Option
+}
+
+// WalkParallel walks the work tree at `root` with a ParallelWalker using
+// the options `opts` and the default settings. See ParallelWalker.Walk.
+func WalkParallel(ctx context.Context, root string, fn WalkFunc, opts ...Option) error {
+	w := &ParallelWalker{Options: opts}
+	return w.Walk(ctx, root, fn)
+}
+
+// Walk walks the work tree at `root`, calling `fn` for each file or
+// directory which is not ignored, `root` included, as Walk does. If `fn`
+// returns filepath.SkipDir for a directory, it is not entered. For a file,
+// the remaining entries of its directory are skipped. Any other error
+// stops the walk and is returned, as is the error of `ctx` once it is
+// done. A sorted walk may still read a directory before `fn` skips it.
+func (w *ParallelWalker) Walk(ctx context.Context, root string, fn WalkFunc) error {
+	r, err := openRepository(root, w.Options)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Lstat(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	d := &statDirEntry{fi}
+	if err := fn(root, d, nil); err != nil || !d.IsDir() {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	workers := w.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	pw := &parallelWalk{repo: r, fn: fn, sorted: w.Sorted, window: workers * readAhead}
+	pw.ctx, pw.cancel = context.WithCancel(ctx)
+	defer pw.cancel()
+	pw.cond = sync.NewCond(&pw.mu)
+
+	// Wake up the idle workers once the walk is stopped
+	go func() {
+		<-pw.ctx.Done()
+		pw.mu.Lock()
+		pw.cond.Broadcast()
+		pw.mu.Unlock()
+	}()
+
+	top := newWalkDir(root, ".", d, r.excludes)
+	pw.push([]*walkDir{top})
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for dir := pw.pop(); dir != nil; dir = pw.pop() {
+				pw.finish(dir, pw.readDir(dir))
+			}
+		}()
+	}
+
+	done := true
+	if pw.sorted {
+		if err := pw.emit(top); err != nil {
+			if err != pw.ctx.Err() {
+				pw.setErr(err)
+			}
+			done = false
+		}
+		// Stop reading the directories which were skipped
+		pw.cancel()
+	}
+	wg.Wait()
+	pw.cancel()
+
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.err != nil {
+		return pw.err
+	}
+	if !done || (!pw.sorted && (pw.stopped || pw.pending > 0)) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// walkDir is a directory to read during a parallel walk.
+type walkDir struct {
+	path string
+	rel  string // the path relative to the top of the work tree
+	d    fs.DirEntry
+
+	// ignores holds the ignore files of the parent directories, by
+	// increasing precedence.
+	ignores []*GitIgnore
+
+	// claimed is true once the directory is read, or about to be, or
+	// skipped. It is guarded by the mutex of the walk.
+	claimed bool
+
+	// The entries which are not ignored, and the error of reading them,
+	// are kept for a sorted walk once done is closed.
+	entries []walkEntry
+	err     error
+	done    chan struct{}
+}
+
+func newWalkDir(fpath, rel string, d fs.DirEntry, ignores []*GitIgnore) *walkDir {
+	return &walkDir{path: fpath, rel: rel, d: d, ignores: ignores, done: make(chan struct{})}
+}
+
+// walkEntry is an entry which is not ignored, with the directory to read
+// if it is a directory.
+type walkEntry struct {
+	path string
+	d    fs.DirEntry
+	dir  *walkDir
+}
+
+// parallelWalk is the state shared by the goroutines of a parallel walk.
+type parallelWalk struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	repo   *Repository
+	fn     WalkFunc
+	sorted bool
+
+	// window is the number of directories which a sorted walk reads ahead
+	// of the callback, and ahead the number of the ones claimed and not
+	// called back for yet.
+	window int
+	ahead  int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []*walkDir // a stack, to read the tree depth first
+	pending int        // the number of directories queued or being read
+	stopped bool       // whether a directory was left partly read
+	err     error      // the first error, which stops the walk
+}
+
+// push queues the directories `dirs`, which are read in order.
+func (pw *parallelWalk) push(dirs []*walkDir) {
+	pw.mu.Lock()
+	for i := len(dirs) - 1; i >= 0; i-- {
+		pw.queue = append(pw.queue, dirs[i])
+	}
+	pw.pending += len(dirs)
+	pw.cond.Broadcast()
+	pw.mu.Unlock()
+}
+
+// pop waits for a directory to read, and claims it. During a sorted walk,
+// it waits as well while the window of directories read ahead of the
+// callback is full. It returns nil once every directory is read or the
+// walk is stopped.
+func (pw *parallelWalk) pop() *walkDir {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	for {
+		for pw.pending > 0 && pw.ctx.Err() == nil && (len(pw.queue) == 0 || pw.sorted && pw.ahead >= pw.window) {
+			pw.cond.Wait()
+		}
+		if len(pw.queue) == 0 || pw.ctx.Err() != nil {
+			return nil
+		}
+		dir := pw.queue[len(pw.queue)-1]
+		pw.queue[len(pw.queue)-1] = nil
+		pw.queue = pw.queue[:len(pw.queue)-1]
+
+		// The emitter claims the directories it waits for, which are then
+		// left in the queue
+		if !dir.claimed {
+			pw.claimLocked(dir)
+			return dir
+		}
+	}
+}
+
+// claim claims the directory `dir` to read it, returning false if it is
+// already claimed.
+func (pw *parallelWalk) claim(dir *walkDir) bool {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if dir.claimed {
+		return false
+	}
+	pw.claimLocked(dir)
+	return true
+}
+
+// claimLocked is like claim, with the mutex held and `dir` unclaimed.
+func (pw *parallelWalk) claimLocked(dir *walkDir) {
+	dir.claimed = true
+	if pw.sorted {
+		pw.ahead++
+	}
+}
+
+// release takes a directory out of the window of a sorted walk, once its
+// entries are called back for or skipped.
+func (pw *parallelWalk) release() {
+	pw.mu.Lock()
+	pw.ahead--
+	if pw.ahead < pw.window {
+		pw.cond.Broadcast()
+	}
+	pw.mu.Unlock()
+}
+
+// finish marks the directory `dir` as read, stopping the walk if `err` is
+// not nil.
+func (pw *parallelWalk) finish(dir *walkDir, err error) {
+	if err != nil && err != pw.ctx.Err() {
+		pw.setErr(err)
+	}
+	pw.mu.Lock()
+	if err != nil {
+		pw.stopped = true
+	}
+	pw.pending--
+	if pw.pending == 0 {
+		pw.cond.Broadcast()
+	}
+	pw.mu.Unlock()
+	close(dir.done)
+}
+
+// setErr stops the walk, keeping the first error.
+func (pw *parallelWalk) setErr(err error) {
+	pw.mu.Lock()
+	if pw.err == nil {
+		pw.err = err
+	}
+	pw.mu.Unlock()
+	pw.cancel()
+}
+
+// readDir loads the .gitignore file of the directory `dir` and reads its
+// entries, queuing the subdirectories which are not ignored. Unless the
+// walk is sorted, it calls back for each entry.
+func (pw *parallelWalk) readDir(dir *walkDir) error {
+	ignores := dir.ignores
+	gi, err := pw.repo.loadIgnoreFile(dir.path, dir.rel)
+	if err != nil {
+		return err
+	}
+	if gi != nil {
+		ignores = append(ignores[:len(ignores):len(ignores)], gi)
+	}
+
+	entries, err := os.ReadDir(dir.path)
+	if err != nil {
+		// Like filepath.WalkDir, call back a second time for the directory
+		if pw.sorted {
+			dir.err = err
+		} else if err := pw.fn(dir.path, dir.d, err); err != nil {
+			if err == filepath.SkipDir {
+				return nil
+			}
+			return err
+		}
+	}
+
+	var dirs []*walkDir
+	for _, e := range entries {
+		if err := pw.ctx.Err(); err != nil {
+			return err
+		}
+		name := e.Name()
+		if name == ".git" {
+			continue
+		}
+		fpath := filepath.Join(dir.path, name)
+		rel := name
+		if dir.rel != "." {
+			rel = filepath.Join(dir.rel, name)
+		}
+		// The parent directories are not ignored, or they would not be read
+		if ip := lastMatchIn(ignores, cleanPath(rel), e.IsDir()); ip != nil && !ip.negate {
+			continue
+		}
+
+		var child *walkDir
+		if e.IsDir() {
+			child = newWalkDir(fpath, rel, e, ignores)
+		}
+		if pw.sorted {
+			dir.entries = append(dir.entries, walkEntry{path: fpath, d: e, dir: child})
+			if child != nil {
+				dirs = append(dirs, child)
+			}
+			continue
+		}
+		if err := pw.fn(fpath, e, nil); err != nil {
+			if err == filepath.SkipDir {
+				if e.IsDir() {
+					continue
+				}
+				break
+			}
+			return err
+		}
+		if child != nil {
+			dirs = append(dirs, child)
+		}
+	}
+	pw.push(dirs)
+	return nil
+}
+
+// emit calls back, in order, for the entries of the directory `dir` and
+// of its subdirectories, waiting for them to be read. It is the only
+// goroutine calling back during a sorted walk. It reads the directories
+// which no worker claimed itself, so that it never waits for the workers
+// waiting for it.
+func (pw *parallelWalk) emit(dir *walkDir) error {
+	if pw.claim(dir) {
+		pw.finish(dir, pw.readDir(dir))
+	}
+	select {
+	case <-dir.done:
+	case <-pw.ctx.Done():
+	}
+	if err := pw.ctx.Err(); err != nil {
+		return err
+	}
+	entries := dir.entries
+	dir.entries = nil
+	pw.release()
+
+	if dir.err != nil {
+		if err := pw.fn(dir.path, dir.d, dir.err); err != nil {
+			if err == filepath.SkipDir {
+				pw.discard(entries)
+				return nil
+			}
+			return err
+		}
+	}
+
+	for i, e := range entries {
+		if err := pw.fn(e.path, e.d, nil); err != nil {
+			if err == filepath.SkipDir {
+				if e.d.IsDir() {
+					pw.discard(entries[i : i+1])
+					continue
+				}
+				pw.discard(entries[i+1:])
+				return nil
+			}
+			return err
+		}
+		if e.dir != nil {
+			if err := pw.emit(e.dir); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// discard takes the directories of the skipped entries `entries`, and their
+// subdirectories, out of the window of a sorted walk, without reading the
+// ones which are not claimed yet.
+func (pw *parallelWalk) discard(entries []walkEntry) {
+	for _, e := range entries {
+		dir := e.dir
+		if dir == nil {
+			continue
+		}
+		pw.mu.Lock()
+		claimed := dir.claimed
+		if !claimed {
+			dir.claimed = true
+			pw.pending--
+			if pw.pending == 0 {
+				pw.cond.Broadcast()
+			}
+		}
+		pw.mu.Unlock()
+		if !claimed {
+			continue
+		}
+
+		select {
+		case <-dir.done:
+		case <-pw.ctx.Done():
+			return
+		}
+		children := dir.entries
+		dir.entries = nil
+		pw.release()
+		pw.discard(children)
+	}
+}
+
+// statDirEntry is a fs.DirEntry built from the os.FileInfo of a file.
+type statDirEntry struct {
+	info fs.FileInfo
+}
+
+func (d *statDirEntry) Name() string               { return d.info.Name() }
+func (d *statDirEntry) IsDir() bool                { return d.info.IsDir() }
+func (d *statDirEntry) Type() fs.FileMode          { return d.info.Mode().Type() }
+func (d *statDirEntry) Info() (fs.FileInfo, error) { return d.info, nil }
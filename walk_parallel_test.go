@@ -0,0 +1,254 @@
+package ignore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeLargeTreeToTestDir is a helper function to setup a temp directory
+// holding a few levels of directories, some of them ignored.
+func writeLargeTreeToTestDir(test *testing.T) string {
+	tree := map[string]string{
+		".gitignore":        "*.log\nnode_modules/\n/build\n",
+		".git/info/exclude": "*.tmp\n",
+		"build/out":         "",
+		"empty/":            "",
+	}
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			dir := fmt.Sprintf("pkg%d/sub%d/", i, j)
+			tree[dir+"main.go"] = ""
+			tree[dir+"debug.log"] = ""
+			tree[dir+"scratch.tmp"] = ""
+			tree[dir+"node_modules/x/index.js"] = ""
+			tree[dir+"build/out"] = ""
+		}
+		tree[fmt.Sprintf("pkg%d/.gitignore", i)] = "!debug.log\nsub[0-3]/\n"
+	}
+	return writeTreeToTestDir(test, tree)
+}
+
+// walkParallelForTest is a helper function returning the slash-separated
+// paths, relative to "root", of the entries a ParallelWalker calls back
+// for, in order.
+func walkParallelForTest(test *testing.T, w *ParallelWalker, root string) []string {
+	var mu sync.Mutex
+	var paths []string
+	err := w.Walk(context.Background(), root, func(fpath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, fpath)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			rel += "/"
+		}
+		mu.Lock()
+		paths = append(paths, filepath.ToSlash(rel))
+		mu.Unlock()
+		return nil
+	})
+	assert.NoError(test, err)
+	return paths
+}
+
+func TestParallelWalkerSorted(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeLargeTreeToTestDir(test)
+	expected := walkForTest(test, root)
+	assert.Contains(test, expected, "pkg0/sub4/debug.log")
+	assert.NotContains(test, expected, "pkg0/sub3/")
+
+	for _, workers := range []int{0, 1, 4, 32} {
+		w := &ParallelWalker{Workers: workers, Sorted: true}
+		assert.Equal(test, expected, walkParallelForTest(test, w, root), "workers: %d", workers)
+	}
+}
+
+func TestParallelWalkerUnsorted(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeLargeTreeToTestDir(test)
+	expected := walkForTest(test, root)
+
+	for _, workers := range []int{0, 1, 4, 32} {
+		w := &ParallelWalker{Workers: workers}
+		paths := walkParallelForTest(test, w, root)
+
+		// A directory always comes before its entries
+		seen := map[string]bool{}
+		for _, p := range paths {
+			if p != "./" {
+				parent := path.Dir(strings.TrimSuffix(p, "/"))
+				if parent == "." {
+					parent = ""
+				}
+				assert.True(test, parent == "" || seen[parent+"/"], "%s should come after its parent", p)
+			}
+			seen[p] = true
+		}
+
+		sort.Strings(paths)
+		assert.Equal(test, expected, paths, "workers: %d", workers)
+	}
+}
+
+func TestParallelWalkerOptions(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeTreeToTestDir(test, map[string]string{
+		".gitignore": "*.LOG\n",
+		"debug.log":  "",
+		"main.go":    "",
+	})
+
+	w := &ParallelWalker{Sorted: true, Options: []Option{WithIgnoreCase()}}
+	assert.Equal(test, []string{"./", ".gitignore", "main.go"}, walkParallelForTest(test, w, root))
+}
+
+func TestParallelWalkerSkipDir(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeTreeToTestDir(test, map[string]string{
+		"a/file":   "",
+		"b/file":   "",
+		"b/other":  "",
+		"c/x/file": "",
+	})
+
+	for _, sorted := range []bool{false, true} {
+		var mu sync.Mutex
+		var paths []string
+		w := &ParallelWalker{Sorted: sorted}
+		err := w.Walk(context.Background(), root, func(fpath string, d fs.DirEntry, err error) error {
+			rel, _ := filepath.Rel(root, fpath)
+			rel = filepath.ToSlash(rel)
+			mu.Lock()
+			paths = append(paths, rel)
+			mu.Unlock()
+			switch rel {
+			case "a", "c/x":
+				return filepath.SkipDir
+			case "b/file":
+				// Skips the rest of "b"
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		assert.NoError(test, err)
+		sort.Strings(paths)
+		assert.Equal(test, []string{".", "a", "b", "b/file", "c", "c/x"}, paths, "sorted: %v", sorted)
+	}
+
+	w := &ParallelWalker{}
+	err := w.Walk(context.Background(), root, func(fpath string, d fs.DirEntry, err error) error {
+		assert.Equal(test, root, fpath, "only the root should be visited")
+		return filepath.SkipDir
+	})
+	assert.NoError(test, err)
+}
+
+// Validate that a sorted walk of more directories than it reads ahead of
+// a slow callback, some of them skipped, calls back as Walk does
+func TestParallelWalkerSortedReadAhead(test *testing.T) {
+	isolateHomeForTest(test)
+	tree := map[string]string{}
+	for i := 0; i < 100; i++ {
+		for j := 0; j < 3; j++ {
+			tree[fmt.Sprintf("d%02d/e%d/file", i, j)] = ""
+		}
+	}
+	root := writeTreeToTestDir(test, tree)
+
+	walkFn := func(paths *[]string) WalkFunc {
+		return func(fpath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			*paths = append(*paths, fpath)
+			if strings.HasSuffix(fpath, "7") || strings.HasSuffix(fpath, filepath.Join("e1", "file")) {
+				return filepath.SkipDir
+			}
+			if len(*paths)%50 == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			return nil
+		}
+	}
+	var expected []string
+	assert.NoError(test, Walk(root, walkFn(&expected)))
+	assert.NotContains(test, expected, filepath.Join(root, "d07", "e0"))
+
+	for _, workers := range []int{1, 4} {
+		var paths []string
+		w := &ParallelWalker{Workers: workers, Sorted: true}
+		assert.NoError(test, w.Walk(context.Background(), root, walkFn(&paths)))
+		assert.Equal(test, expected, paths, "workers: %d", workers)
+	}
+}
+
+func TestParallelWalkerErrors(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeLargeTreeToTestDir(test)
+
+	stop := errors.New("stop")
+	for _, sorted := range []bool{false, true} {
+		w := &ParallelWalker{Workers: 4, Sorted: sorted}
+		err := w.Walk(context.Background(), root, func(fpath string, d fs.DirEntry, err error) error {
+			if filepath.Base(fpath) == "main.go" {
+				return stop
+			}
+			return nil
+		})
+		assert.Equal(test, stop, err, "sorted: %v", sorted)
+	}
+
+	doesntexist := filepath.Join(root, "doesntexist")
+	err := WalkParallel(context.Background(), doesntexist, func(fpath string, d fs.DirEntry, err error) error {
+		assert.Equal(test, doesntexist, fpath)
+		assert.Nil(test, d)
+		return err
+	})
+	assert.True(test, os.IsNotExist(err), "error should be unknown file / dir")
+}
+
+func TestParallelWalkerCancel(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeLargeTreeToTestDir(test)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := WalkParallel(ctx, root, func(fpath string, d fs.DirEntry, err error) error {
+		return err
+	})
+	assert.Equal(test, context.Canceled, err)
+
+	for _, sorted := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		var mu sync.Mutex
+		count := 0
+		w := &ParallelWalker{Workers: 4, Sorted: sorted}
+		err := w.Walk(ctx, root, func(fpath string, d fs.DirEntry, err error) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if count++; count == 10 {
+				cancel()
+			}
+			return err
+		})
+		cancel()
+		assert.Equal(test, context.Canceled, err, "sorted: %v", sorted)
+		assert.Less(test, count, len(walkForTest(test, root)), "sorted: %v", sorted)
+	}
+}
@@ -0,0 +1,202 @@
+package ignore
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// walkForTest is a helper function returning the slash-separated paths,
+// relative to "root", of the entries Walk calls back for.
+func walkForTest(test *testing.T, root string, opts ...Option) []string {
+	var paths []string
+	err := Walk(root, func(fpath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, fpath)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			rel += "/"
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	}, opts...)
+	assert.NoError(test, err)
+	return paths
+}
+
+func TestWalk(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeTreeToTestDir(test, map[string]string{
+		".gitignore":                  "*.log\nnode_modules/\n/build\n",
+		".git/HEAD":                   "",
+		".git/info/exclude":           "*.tmp\n",
+		"main.go":                     "",
+		"debug.log":                   "",
+		"scratch.tmp":                 "",
+		"build/out":                   "",
+		"node_modules/x/index.js":     "",
+		"src/.gitignore":              "!keep.log\n*.gen.go\n",
+		"src/keep.log":                "",
+		"src/other.log":               "",
+		"src/lib.go":                  "",
+		"src/lib.gen.go":              "",
+		"src/node_modules/y/index.js": "",
+		"src/vendor/build/file.go":    "",
+		"src/vendor/build/.gitignore": "!*\n",
+		"docs/empty/":                 "",
+	})
+
+	assert.Equal(test, []string{
+		"./",
+		".gitignore",
+		"docs/",
+		"docs/empty/",
+		"main.go",
+		"src/",
+		"src/.gitignore",
+		"src/keep.log",
+		"src/lib.go",
+		"src/vendor/",
+		"src/vendor/build/",
+		"src/vendor/build/.gitignore",
+		"src/vendor/build/file.go",
+	}, walkForTest(test, root))
+}
+
+func TestWalkIgnoreCase(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeTreeToTestDir(test, map[string]string{
+		".gitignore": "*.LOG\n",
+		"debug.log":  "",
+		"main.go":    "",
+	})
+
+	assert.Equal(test, []string{"./", ".gitignore", "debug.log", "main.go"}, walkForTest(test, root))
+	assert.Equal(test, []string{"./", ".gitignore", "main.go"}, walkForTest(test, root, WithIgnoreCase()))
+}
+
+func TestWalkPrunesIgnoredDirectories(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeTreeToTestDir(test, map[string]string{
+		".gitignore":     "/private\n",
+		"private/secret": "",
+		"public/file":    "",
+	})
+
+	// Walk should never read inside of an ignored directory
+	private := filepath.Join(root, "private")
+	assert.NoError(test, os.Chmod(private, 0))
+	defer os.Chmod(private, os.ModePerm)
+
+	assert.Equal(test, []string{"./", ".gitignore", "public/", "public/file"}, walkForTest(test, root))
+}
+
+// Validate that Walk makes the same decisions as a Repository
+func TestWalkMatchesRepository(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeLargeTreeToTestDir(test)
+	r, err := NewRepository(root)
+	assert.NoError(test, err)
+
+	var expected []string
+	err = filepath.WalkDir(root, func(fpath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, fpath)
+		if err != nil {
+			return err
+		}
+		if rel == ".git" {
+			return filepath.SkipDir
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			rel += "/"
+		}
+		if rel == "./" || !r.MatchesPath(rel) {
+			expected = append(expected, rel)
+		}
+		return nil
+	})
+	assert.NoError(test, err)
+	assert.Contains(test, expected, "pkg0/sub4/main.go")
+	assert.Equal(test, expected, walkForTest(test, root))
+}
+
+// Validate that, like git, a trailing "/**" does not match the path itself
+func TestWalkTrailingDoubleStar(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeTreeToTestDir(test, map[string]string{
+		".gitignore": "*/**\n",
+		"README":     "",
+		"main.go":    "",
+		"src/lib.go": "",
+	})
+	assert.Equal(test, []string{"./", ".gitignore", "README", "main.go", "src/"}, walkForTest(test, root))
+
+	root = writeTreeToTestDir(test, map[string]string{
+		".gitignore": "abc/**\n!abc/keep\n",
+		"abc/keep":   "",
+		"abc/other":  "",
+	})
+	assert.Equal(test, []string{"./", ".gitignore", "abc/", "abc/keep"}, walkForTest(test, root))
+}
+
+func TestWalkSkipDir(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeTreeToTestDir(test, map[string]string{
+		"a/.gitignore": "*\n",
+		"a/file":       "",
+		"b/file":       "",
+	})
+
+	var paths []string
+	err := Walk(root, func(fpath string, d fs.DirEntry, err error) error {
+		rel, _ := filepath.Rel(root, fpath)
+		paths = append(paths, filepath.ToSlash(rel))
+		if d.IsDir() && d.Name() == "a" {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	assert.NoError(test, err)
+	assert.Equal(test, []string{".", "a", "b", "b/file"}, paths)
+}
+
+func TestWalkErrors(test *testing.T) {
+	isolateHomeForTest(test)
+	root := writeTreeToTestDir(test, map[string]string{
+		"a/file": "",
+		"b/file": "",
+	})
+
+	stop := errors.New("stop")
+	var paths []string
+	err := Walk(root, func(fpath string, d fs.DirEntry, err error) error {
+		rel, _ := filepath.Rel(root, fpath)
+		paths = append(paths, filepath.ToSlash(rel))
+		if rel == filepath.Join("a", "file") {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(test, stop, err)
+	assert.Equal(test, []string{".", "a", "a/file"}, paths)
+
+	doesntexist := filepath.Join(root, "doesntexist")
+	err = Walk(doesntexist, func(fpath string, d fs.DirEntry, err error) error {
+		assert.Equal(test, doesntexist, fpath)
+		assert.Nil(test, d)
+		return err
+	})
+	assert.True(test, os.IsNotExist(err), "error should be unknown file / dir")
+}
@@ -0,0 +1,165 @@
+package ignore
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// wildResult is the outcome of matching the tokens of a pattern from a
+// position of a path. Like in git's wildmatch.c, the aborts tell the
+// callers that trying the following positions of the path is pointless.
+type wildResult int
+
+const (
+	wildNoMatch wildResult = iota
+	wildMatch
+	// wildAbortAll means the path is too short for the tokens left
+	wildAbortAll
+	// wildAbortToDirs means a "*" reached the end of a path component, so
+	// that only a "**" can go further
+	wildAbortToDirs
+)
+
+// wildmatcher matches a path against the tokens of a pattern, following
+// git's wildmatch.c.
+type wildmatcher struct {
+	tokens     []token
+	text       string
+	ignoreCase bool
+}
+
+// wildmatch returns true if the tokens of a pattern match the whole path
+// `text`. Unless `anchored` is true, the pattern may match the path of any
+// directory level, i.e. its basename. It matches regardless of case if
+// `ignoreCase` is true.
+func wildmatch(tokens []token, text string, anchored, ignoreCase bool) bool {
+	m := wildmatcher{tokens: tokens, text: text, ignoreCase: ignoreCase}
+	if anchored {
+		return m.match(0, 0) == wildMatch
+	}
+	return m.matchDirs(0, 0) == wildMatch
+}
+
+// match matches the tokens from `ti` against the path from `si`.
+func (m *wildmatcher) match(ti, si int) wildResult {
+	for ; ti < len(m.tokens); ti++ {
+		t := &m.tokens[ti]
+		switch t.kind {
+		case tokenLiteral:
+			if si == len(m.text) {
+				return wildAbortAll
+			}
+			if !m.hasPrefix(m.text[si:], t.text) {
+				return wildNoMatch
+			}
+			si += len(t.text)
+		case tokenQuestion, tokenClass:
+			if si == len(m.text) {
+				return wildAbortAll
+			}
+			r, n := utf8.DecodeRuneInString(m.text[si:])
+			if r == '/' || (t.kind == tokenClass && !t.class.matches(r, m.ignoreCase)) {
+				return wildNoMatch
+			}
+			si += n
+		case tokenStar:
+			return m.matchStar(ti+1, si)
+		case tokenDirs:
+			return m.matchDirs(ti+1, si)
+		case tokenSubtree:
+			// Like git, anything inside, but not the path itself
+			if si+1 < len(m.text) && m.text[si] == '/' {
+				return wildMatch
+			}
+			return wildNoMatch
+		case tokenAny:
+			return wildMatch
+		}
+	}
+	if si == len(m.text) {
+		return wildMatch
+	}
+	return wildNoMatch
+}
+
+// matchStar matches a "*" followed by the tokens from `ti` against the
+// path from `si`, trying every length of the run the "*" stands for.
+func (m *wildmatcher) matchStar(ti, si int) wildResult {
+	if ti == len(m.tokens) {
+		// A trailing "*" matches the rest of the path component
+		if strings.IndexByte(m.text[si:], '/') >= 0 {
+			return wildAbortToDirs
+		}
+		return wildMatch
+	}
+
+	// Only try the positions where a literal which follows may start
+	var first byte
+	literal := m.tokens[ti].kind == tokenLiteral && !m.ignoreCase
+	if literal {
+		first = m.tokens[ti].text[0]
+	}
+	for {
+		if !literal || (si < len(m.text) && m.text[si] == first) {
+			if r := m.match(ti, si); r != wildNoMatch {
+				return r
+			}
+		}
+		if si == len(m.text) {
+			return wildAbortAll
+		}
+		if m.text[si] == '/' {
+			return wildAbortToDirs
+		}
+		_, n := utf8.DecodeRuneInString(m.text[si:])
+		si += n
+	}
+}
+
+// matchDirs matches a "**/", zero or more directories, followed by the
+// tokens from `ti` against the path from `si`.
+func (m *wildmatcher) matchDirs(ti, si int) wildResult {
+	for {
+		if r := m.match(ti, si); r != wildNoMatch && r != wildAbortToDirs {
+			return r
+		}
+		i := strings.IndexByte(m.text[si:], '/')
+		if i < 0 {
+			return wildAbortAll
+		}
+		si += i + 1
+	}
+}
+
+// hasPrefix returns true if `s` starts with the literal `lit`, regardless
+// of case if the matcher ignores it.
+func (m *wildmatcher) hasPrefix(s, lit string) bool {
+	if !m.ignoreCase {
+		return strings.HasPrefix(s, lit)
+	}
+	return len(s) >= len(lit) && equalFoldASCII(s[:len(lit)], lit)
+}
+
+// equalFoldASCII returns true if the strings `a` and `b` are equal,
+// regardless of the case of ASCII letters only. Like git's
+// core.ignoreCase, other letters, such as "ü" and "Ü", are distinct.
+func equalFoldASCII(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		if lowerASCII(a[i]) != lowerASCII(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// lowerASCII returns the lower case of `c` if it is an upper case ASCII
+// letter, or `c` itself otherwise.
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
@@ -0,0 +1,140 @@
+package ignore
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWildmatchSpecialCharacters(test *testing.T) {
+	object := CompileIgnoreLines("#$~", `\#$~_*`, "a\nb", "*.log", "/**/x")
+
+	assert.False(test, object.MatchesPath("#$~"), "#$~ should not match, it is a comment")
+	assert.True(test, object.MatchesPath("#$~_tmp"), "#$~_tmp should match")
+	assert.True(test, object.MatchesPath("dir/#$~_"), "dir/#$~_ should match")
+	assert.True(test, object.MatchesPath("a\nb"), "a\\nb should match")
+	assert.True(test, object.MatchesPath("new\nline.log"), "new\\nline.log should match")
+	assert.True(test, object.MatchesPath("a\n/b/x"), "a\\n/b/x should match")
+	assert.False(test, object.MatchesPath("a\nc"), "a\\nc should not match")
+}
+
+func TestWildmatchInvalidUTF8(test *testing.T) {
+	object := CompileIgnoreLines("a\xffb", "c?d", "[\xfe]")
+
+	assert.True(test, object.MatchesPath("a\xffb"), "a\\xffb should match")
+	assert.False(test, object.MatchesPath("a\xfeb"), "a\\xfeb should not match")
+	assert.True(test, object.MatchesPath("c\xffd"), "c\\xffd should match")
+	assert.False(test, object.MatchesPath("c\xff\xffd"), "c\\xff\\xffd should not match")
+
+	object = New(WithIgnoreCase()).AddPatternsFromLines("A\xffB")
+	assert.True(test, object.MatchesPath("a\xffb"), "a\\xffb should match")
+	assert.False(test, object.MatchesPath("a\xfeb"), "a\\xfeb should not match")
+	assert.False(test, object.MatchesPath("a�b"), "a\\uFFFDb should not match")
+}
+
+func TestWildmatchIgnoreCaseFolding(test *testing.T) {
+	object := New(WithIgnoreCase()).AddPatternsFromLines("ü.txt", "kelvin", "[σ]x", "[^k]y", "s?b", "[a-c]d")
+
+	// Like git, only ASCII letters are folded
+	assert.True(test, object.MatchesPath("ü.TXT"), "ü.TXT should match")
+	assert.False(test, object.MatchesPath("Ü.TXT"), "Ü.TXT should not match")
+	assert.True(test, object.MatchesPath("KELVIN"), "KELVIN should match")
+	assert.False(test, object.MatchesPath("\u212Aelvin"), "\\u212Aelvin should not match")
+	assert.True(test, object.MatchesPath("σX"), "σX should match")
+	assert.False(test, object.MatchesPath("Σx"), "Σx should not match")
+	assert.False(test, object.MatchesPath("KY"), "KY should not match")
+	assert.True(test, object.MatchesPath("\u212Ay"), "\\u212Ay should match")
+	assert.True(test, object.MatchesPath("SüB"), "SüB should match")
+	assert.True(test, object.MatchesPath("BD"), "BD should match")
+}
+
+// Validate that git's aborts keep the matching time linear on patterns
+// which make a naive backtracking matcher explode
+func TestWildmatchBacktracking(test *testing.T) {
+	text := strings.Repeat("a", 64)
+	patterns := []string{
+		strings.Repeat("*a", 16) + "b",
+		"/" + strings.Repeat("**/a", 16) + "/b",
+		strings.Repeat("a*", 16) + "b",
+	}
+	object := CompileIgnoreLines(patterns...)
+
+	start := time.Now()
+	assert.False(test, object.MatchesPath(text), "%s should not match", text)
+	assert.False(test, object.MatchesPath(strings.Repeat("a/", 32)), "a/... should not match")
+	assert.True(test, object.MatchesPath(text+"b"), "%sb should match", text)
+	assert.Less(test, int64(time.Since(start)), int64(time.Second))
+}
+
+// naiveWildmatch is a reference implementation of wildmatch, without any
+// of git's aborts.
+func naiveWildmatch(tokens []token, text string, ignoreCase bool) bool {
+	if len(tokens) == 0 {
+		return text == ""
+	}
+	t, rest := tokens[0], tokens[1:]
+	switch t.kind {
+	case tokenLiteral:
+		m := wildmatcher{ignoreCase: ignoreCase}
+		return m.hasPrefix(text, t.text) && naiveWildmatch(rest, text[len(t.text):], ignoreCase)
+	case tokenQuestion, tokenClass:
+		r, n := utf8.DecodeRuneInString(text)
+		if text == "" || r == '/' || (t.kind == tokenClass && !t.class.matches(r, ignoreCase)) {
+			return false
+		}
+		return naiveWildmatch(rest, text[n:], ignoreCase)
+	case tokenStar:
+		for i := 0; ; i++ {
+			if naiveWildmatch(rest, text[i:], ignoreCase) {
+				return true
+			}
+			if i == len(text) || text[i] == '/' {
+				return false
+			}
+		}
+	case tokenDirs:
+		for i := 0; i <= len(text); i++ {
+			if (i == 0 || text[i-1] == '/') && naiveWildmatch(rest, text[i:], ignoreCase) {
+				return true
+			}
+		}
+		return false
+	case tokenSubtree:
+		return len(text) > 1 && text[0] == '/'
+	default:
+		return true
+	}
+}
+
+func TestWildmatchRandom(test *testing.T) {
+	pieces := []string{"a", "b", "A", "/", "*", "?", "**/", "/**", "[ab]", "[!a]", "\\*"}
+	texts := []string{"a", "b", "B", "/", "*", "ab"}
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 20000; i++ {
+		var p, t strings.Builder
+		for n := rng.Intn(6) + 1; n > 0; n-- {
+			p.WriteString(pieces[rng.Intn(len(pieces))])
+		}
+		for n := rng.Intn(8); n > 0; n-- {
+			t.WriteString(texts[rng.Intn(len(texts))])
+		}
+		pattern, text := p.String(), t.String()
+
+		tokens, perr := tokenize(pattern)
+		if perr != nil {
+			continue
+		}
+		for _, ignoreCase := range []bool{false, true} {
+			expected := naiveWildmatch(tokens, text, ignoreCase)
+			actual := wildmatch(tokens, text, true, ignoreCase)
+			if !assert.Equal(test, expected, actual, "%q against %q, ignoreCase: %v", pattern, text, ignoreCase) {
+				return
+			}
+		}
+	}
+}